@@ -4,7 +4,9 @@
 package caldav
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	webdav "github.com/trvita/caldav-client-yandex"
@@ -64,13 +66,45 @@ func ValidateCalendarObject(cal *ical.Calendar) (eventType string, uid string, e
 }
 
 type Calendar struct {
-	Path                  string
-	Name                  string
-	Description           string
+	Path        string
+	Name        string
+	Description string
+	// Color is a CSS color such as "#FF0000", as used by the Apple
+	// calendar-color property.
+	Color string
+	// Timezone is an iCalendar object containing the VTIMEZONE used to
+	// interpret floating times and date values.
+	Timezone              string
 	MaxResourceSize       int64
 	SupportedComponentSet []string
 }
 
+// CalendarUpdate describes changes to the properties of a calendar. Nil
+// fields are left unchanged, and fields pointing to an empty string remove
+// the property.
+type CalendarUpdate struct {
+	Name        *string
+	Description *string
+	Color       *string
+	Timezone    *string
+}
+
+func (update *CalendarUpdate) apply(cal *Calendar) {
+	for _, f := range []struct {
+		v    *string
+		dest *string
+	}{
+		{update.Name, &cal.Name},
+		{update.Description, &cal.Description},
+		{update.Color, &cal.Color},
+		{update.Timezone, &cal.Timezone},
+	} {
+		if f.v != nil {
+			*f.dest = *f.v
+		}
+	}
+}
+
 type CalendarCompRequest struct {
 	Name string
 
@@ -79,6 +113,23 @@ type CalendarCompRequest struct {
 
 	AllComps bool
 	Comps    []CalendarCompRequest
+
+	// Expand requests recurring components to be replaced with their
+	// instances overlapping a time range. LimitRecurrenceSet only keeps
+	// the overridden instances overlapping a time range. At most one of
+	// them can be set, and only on the top-level request.
+	Expand             *CalendarExpandRequest
+	LimitRecurrenceSet *CalendarExpandRequest
+	// LimitFreeBusySet only keeps the FREEBUSY periods of VFREEBUSY
+	// components overlapping a time range. It can only be set on the
+	// top-level request.
+	LimitFreeBusySet *CalendarExpandRequest
+}
+
+// CalendarExpandRequest is the time range of a recurrence set request. Both
+// Start and End are required.
+type CalendarExpandRequest struct {
+	Start, End time.Time
 }
 
 type CompFilter struct {
@@ -106,8 +157,18 @@ type PropFilter struct {
 type TextMatch struct {
 	Text            string
 	NegateCondition bool
+	Collation       Collation // defaults to CollationASCIICasemap
 }
 
+// Collation is a text-match collation, as described in RFC 4791 section 7.5.
+type Collation string
+
+const (
+	CollationOctet          Collation = internal.CollationOctet
+	CollationASCIICasemap   Collation = internal.CollationASCIICasemap
+	CollationUnicodeCasemap Collation = internal.CollationUnicodeCasemap
+)
+
 type CalendarQuery struct {
 	CompRequest CalendarCompRequest
 	CompFilter  CompFilter
@@ -125,3 +186,75 @@ type CalendarObject struct {
 	ETag          string
 	Data          *ical.Calendar
 }
+
+// SyncQuery is the query struct represents a sync-collection request
+type SyncQuery struct {
+	CompRequest CalendarCompRequest
+	SyncToken   string
+	Limit       int // <= 0 means unlimited
+}
+
+// SyncResponse contains the returned sync-token for next time
+type SyncResponse struct {
+	SyncToken string
+	Updated   []CalendarObject
+	Deleted   []string
+	// Truncated is set if the result was limited and the client needs to
+	// issue another request with SyncToken to get the remaining changes
+	Truncated bool
+}
+
+// ConflictError is returned by Client.PutCalendarObject when the server
+// rejects a conditional write with 412 Precondition Failed, because the
+// calendar object already exists or has been modified since it was fetched.
+type ConflictError struct {
+	Path string
+	Err  error
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("caldav: conflicting write to %q: %v", err.Path, err.Err)
+}
+
+func (err *ConflictError) Unwrap() error {
+	return err.Err
+}
+
+func newConflictError(path string, err error) error {
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusPreconditionFailed {
+		return &ConflictError{Path: path, Err: err}
+	}
+	return err
+}
+
+// PreconditionError is returned by Client methods when the server rejects a
+// request because a precondition failed. For PreconditionNoUIDConflict, Href
+// is the path of the calendar object already using the UID, if the server
+// reported it.
+type PreconditionError struct {
+	Precondition PreconditionType
+	Href         string
+	Err          error
+}
+
+func (err *PreconditionError) Error() string {
+	if err.Href != "" {
+		return fmt.Sprintf("caldav: precondition %q failed: conflicts with %q", err.Precondition, err.Href)
+	}
+	return fmt.Sprintf("caldav: precondition %q failed", err.Precondition)
+}
+
+func (err *PreconditionError) Unwrap() error {
+	return err.Err
+}
+
+// newPreconditionError returns a *PreconditionError if err carries a
+// CALDAV precondition element, and err unchanged otherwise.
+func newPreconditionError(err error) error {
+	name, href, ok := internal.DecodePrecondition(err, namespace)
+	if !ok {
+		return err
+	}
+	return &PreconditionError{Precondition: PreconditionType(name), Href: href, Err: err}
+}
@@ -3,6 +3,7 @@ package caldav
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
 	"fmt"
 	"mime"
 	"net/http"
@@ -61,6 +62,8 @@ func (c *Client) FindCalendars(ctx context.Context, calendarHomeSet string) ([]C
 		internal.ResourceTypeName,
 		internal.DisplayNameName,
 		calendarDescriptionName,
+		calendarColorName,
+		calendarTimezoneName,
 		maxResourceSizeName,
 		supportedCalendarComponentSetName,
 	)
@@ -94,6 +97,16 @@ func (c *Client) FindCalendars(ctx context.Context, calendarHomeSet string) ([]C
 			return nil, err
 		}
 
+		var color calendarColor
+		if err := resp.DecodeProp(&color); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
+		var tz calendarTimezone
+		if err := resp.DecodeProp(&tz); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
 		var maxResSize maxResourceSize
 		if err := resp.DecodeProp(&maxResSize); err != nil && !internal.IsNotFound(err) {
 			return nil, err
@@ -116,6 +129,8 @@ func (c *Client) FindCalendars(ctx context.Context, calendarHomeSet string) ([]C
 			Path:                  path,
 			Name:                  dispName.Name,
 			Description:           desc.Description,
+			Color:                 color.Color,
+			Timezone:              tz.Data,
 			MaxResourceSize:       maxResSize.Size,
 			SupportedComponentSet: compNames,
 		})
@@ -124,6 +139,144 @@ func (c *Client) FindCalendars(ctx context.Context, calendarHomeSet string) ([]C
 	return l, nil
 }
 
+// CreateCalendar creates a calendar collection at calendar.Path. The display
+// name, description, color, timezone and supported component set are set
+// when non-empty; MaxResourceSize is chosen by the server and ignored.
+//
+// MKCALENDAR is used, falling back to an extended MKCOL request if the server
+// doesn't support it. Creating a calendar at an existing path fails.
+func (c *Client) CreateCalendar(ctx context.Context, calendar *Calendar) error {
+	var values []interface{}
+	if calendar.Name != "" {
+		values = append(values, &internal.DisplayName{Name: calendar.Name})
+	}
+	if calendar.Description != "" {
+		values = append(values, &calendarDescription{Description: calendar.Description})
+	}
+	if calendar.Color != "" {
+		values = append(values, &calendarColor{Color: calendar.Color})
+	}
+	if calendar.Timezone != "" {
+		values = append(values, &calendarTimezone{Data: calendar.Timezone})
+	}
+	if len(calendar.SupportedComponentSet) > 0 {
+		var compSet supportedCalendarComponentSet
+		for _, name := range calendar.SupportedComponentSet {
+			compSet.Comp = append(compSet.Comp, comp{Name: name})
+		}
+		values = append(values, &compSet)
+	}
+
+	var set *internal.Set
+	if len(values) > 0 {
+		prop, err := internal.EncodeProp(values...)
+		if err != nil {
+			return err
+		}
+		set = &internal.Set{Prop: *prop}
+	}
+
+	req, err := c.ic.NewXMLRequest("MKCALENDAR", calendar.Path, &mkcalendarReq{Set: set})
+	if err != nil {
+		return err
+	}
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if c.mkcalendarUnsupported(ctx, calendar.Path, err) {
+		resp, err = c.mkcolCalendar(ctx, calendar.Path, values)
+	}
+	if err != nil {
+		return newPreconditionError(err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// UpdateCalendar changes the properties of the calendar at path, and returns
+// the status of each property. Updates are atomic: if a property can't be
+// updated, none are and an error is returned along with the statuses.
+func (c *Client) UpdateCalendar(ctx context.Context, path string, update *CalendarUpdate) ([]webdav.PropStatus, error) {
+	var values internal.PropertyUpdateValues
+	values.Add(update.Name, func(s string) interface{} {
+		return &internal.DisplayName{Name: s}
+	})
+	values.Add(update.Description, func(s string) interface{} {
+		return &calendarDescription{Description: s}
+	})
+	values.Add(update.Color, func(s string) interface{} {
+		return &calendarColor{Color: s}
+	})
+	values.Add(update.Timezone, func(s string) interface{} {
+		return &calendarTimezone{Data: s}
+	})
+	if values.IsEmpty() {
+		return nil, nil
+	}
+
+	pu, err := internal.NewPropertyUpdate(values.Set, values.Remove)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.ic.PropPatch(ctx, path, pu)
+	if err != nil {
+		return nil, err
+	}
+	return decodePropStatuses(resp)
+}
+
+func decodePropStatuses(resp *internal.Response) ([]webdav.PropStatus, error) {
+	var l []webdav.PropStatus
+	err := internal.DecodePropertyUpdate(resp, "caldav", func(name xml.Name, code int, err error) {
+		l = append(l, webdav.PropStatus{Name: name, Code: code, Err: err})
+	})
+	return l, err
+}
+
+// mkcalendarUnsupported checks whether a failed MKCALENDAR request should be
+// retried with an extended MKCOL request. Servers also reply with 405 Method
+// Not Allowed when a resource already exists at path, so in that case the
+// error is kept.
+func (c *Client) mkcalendarUnsupported(ctx context.Context, path string, err error) bool {
+	httpErr, ok := err.(*internal.HTTPError)
+	if !ok {
+		return false
+	}
+	switch httpErr.Code {
+	case http.StatusNotImplemented:
+		return true
+	case http.StatusMethodNotAllowed:
+		// Handled below
+	default:
+		return false
+	}
+
+	if davErr, ok := httpErr.Err.(*internal.Error); ok {
+		if raw := davErr.Condition(internal.Namespace); raw != nil {
+			if name, _ := raw.XMLName(); name.Local == "resource-must-be-null" {
+				return false
+			}
+		}
+	}
+
+	// Not all servers report a precondition, check whether path exists
+	propfind := internal.NewPropNamePropFind(internal.ResourceTypeName)
+	_, err = c.ic.PropFindFlat(ctx, path, propfind)
+	return internal.IsNotFound(err)
+}
+
+func (c *Client) mkcolCalendar(ctx context.Context, path string, values []interface{}) (*http.Response, error) {
+	values = append([]interface{}{internal.NewResourceType(internal.CollectionName, calendarName)}, values...)
+	prop, err := internal.EncodeProp(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.ic.NewXMLRequest("MKCOL", path, &mkcolReq{Set: &internal.Set{Prop: *prop}})
+	if err != nil {
+		return nil, err
+	}
+	return c.ic.Do(req.WithContext(ctx))
+}
+
 func encodeCalendarCompReq(c *CalendarCompRequest) (*comp, error) {
 	encoded := comp{Name: c.Name}
 
@@ -155,24 +308,123 @@ func encodeCalendarReq(c *CalendarCompRequest) (*internal.Prop, error) {
 	}
 
 	calDataReq := calendarDataReq{Comp: compReq}
+	if c.Expand != nil && c.LimitRecurrenceSet != nil {
+		return nil, fmt.Errorf("caldav: only one of expand or limit-recurrence-set can be requested")
+	}
+	if c.Expand != nil {
+		if c.Expand.Start.IsZero() || c.Expand.End.IsZero() {
+			return nil, fmt.Errorf("caldav: expand requires a start and end time")
+		}
+		calDataReq.Expand = &expand{
+			Start: dateWithUTCTime(c.Expand.Start),
+			End:   dateWithUTCTime(c.Expand.End),
+		}
+	}
+	if c.LimitRecurrenceSet != nil {
+		if c.LimitRecurrenceSet.Start.IsZero() || c.LimitRecurrenceSet.End.IsZero() {
+			return nil, fmt.Errorf("caldav: limit-recurrence-set requires a start and end time")
+		}
+		calDataReq.LimitRecurrenceSet = &limitRecurrenceSet{
+			Start: dateWithUTCTime(c.LimitRecurrenceSet.Start),
+			End:   dateWithUTCTime(c.LimitRecurrenceSet.End),
+		}
+	}
+	if c.LimitFreeBusySet != nil {
+		if c.LimitFreeBusySet.Start.IsZero() || c.LimitFreeBusySet.End.IsZero() {
+			return nil, fmt.Errorf("caldav: limit-freebusy-set requires a start and end time")
+		}
+		calDataReq.LimitFreeBusySet = &limitFreeBusySet{
+			Start: dateWithUTCTime(c.LimitFreeBusySet.Start),
+			End:   dateWithUTCTime(c.LimitFreeBusySet.End),
+		}
+	}
 
 	getLastModReq := internal.NewRawXMLElement(internal.GetLastModifiedName, nil, nil)
 	getETagReq := internal.NewRawXMLElement(internal.GetETagName, nil, nil)
 	return internal.EncodeProp(&calDataReq, getLastModReq, getETagReq)
 }
 
-func encodeCompFilter(filter *CompFilter) *compFilter {
+func encodeTimeRange(start, end time.Time) *timeRange {
+	if start.IsZero() && end.IsZero() {
+		return nil
+	}
+	return &timeRange{
+		Start: dateWithUTCTime(start),
+		End:   dateWithUTCTime(end),
+	}
+}
+
+func encodeCompFilter(filter *CompFilter) (*compFilter, error) {
 	encoded := compFilter{Name: filter.Name}
-	if !filter.Start.IsZero() || !filter.End.IsZero() {
-		encoded.TimeRange = &timeRange{
-			Start: dateWithUTCTime(filter.Start),
-			End:   dateWithUTCTime(filter.End),
+	if filter.IsNotDefined {
+		if !filter.Start.IsZero() || !filter.End.IsZero() || len(filter.Props) > 0 || len(filter.Comps) > 0 {
+			return nil, fmt.Errorf("caldav: failed to encode CompFilter: IsNotDefined cannot be set with a time range, Props or Comps")
+		}
+		encoded.IsNotDefined = &struct{}{}
+	}
+	encoded.TimeRange = encodeTimeRange(filter.Start, filter.End)
+	for _, pf := range filter.Props {
+		pfEl, err := encodePropFilter(&pf)
+		if err != nil {
+			return nil, err
 		}
+		encoded.PropFilters = append(encoded.PropFilters, *pfEl)
 	}
 	for _, child := range filter.Comps {
-		encoded.CompFilters = append(encoded.CompFilters, *encodeCompFilter(&child))
+		childEl, err := encodeCompFilter(&child)
+		if err != nil {
+			return nil, err
+		}
+		encoded.CompFilters = append(encoded.CompFilters, *childEl)
+	}
+	return &encoded, nil
+}
+
+func encodePropFilter(pf *PropFilter) (*propFilter, error) {
+	el := &propFilter{Name: pf.Name}
+	if pf.IsNotDefined {
+		if !pf.Start.IsZero() || !pf.End.IsZero() || pf.TextMatch != nil || len(pf.ParamFilter) > 0 {
+			return nil, fmt.Errorf("caldav: failed to encode PropFilter: IsNotDefined cannot be set with a time range, TextMatch or ParamFilter")
+		}
+		el.IsNotDefined = &struct{}{}
+	}
+	if pf.TextMatch != nil && (!pf.Start.IsZero() || !pf.End.IsZero()) {
+		return nil, fmt.Errorf("caldav: failed to encode PropFilter: only one of a time range or TextMatch can be set")
+	}
+	el.TimeRange = encodeTimeRange(pf.Start, pf.End)
+	if pf.TextMatch != nil {
+		el.TextMatch = encodeTextMatch(pf.TextMatch)
+	}
+	for _, param := range pf.ParamFilter {
+		paramEl, err := encodeParamFilter(&param)
+		if err != nil {
+			return nil, err
+		}
+		el.ParamFilter = append(el.ParamFilter, *paramEl)
+	}
+	return el, nil
+}
+
+func encodeParamFilter(pf *ParamFilter) (*paramFilter, error) {
+	el := &paramFilter{Name: pf.Name}
+	if pf.IsNotDefined {
+		if pf.TextMatch != nil {
+			return nil, fmt.Errorf("caldav: failed to encode ParamFilter: only one of IsNotDefined or TextMatch can be set")
+		}
+		el.IsNotDefined = &struct{}{}
+	}
+	if pf.TextMatch != nil {
+		el.TextMatch = encodeTextMatch(pf.TextMatch)
+	}
+	return el, nil
+}
+
+func encodeTextMatch(tm *TextMatch) *textMatch {
+	return &textMatch{
+		Text:            tm.Text,
+		Collation:       string(tm.Collation),
+		NegateCondition: negateCondition(tm.NegateCondition),
 	}
-	return &encoded
 }
 
 func decodeCalendarObjectList(ms *internal.MultiStatus) ([]CalendarObject, error) {
@@ -227,8 +479,13 @@ func (c *Client) QueryCalendar(ctx context.Context, calendar string, query *Cale
 		return nil, err
 	}
 
+	compFilterEl, err := encodeCompFilter(&query.CompFilter)
+	if err != nil {
+		return nil, err
+	}
+
 	calendarQuery := calendarQuery{Prop: propReq}
-	calendarQuery.Filter.CompFilter = *encodeCompFilter(&query.CompFilter)
+	calendarQuery.Filter.CompFilter = *compFilterEl
 	req, err := c.ic.NewXMLRequest("REPORT", calendar, &calendarQuery)
 	if err != nil {
 		return nil, err
@@ -237,12 +494,54 @@ func (c *Client) QueryCalendar(ctx context.Context, calendar string, query *Cale
 
 	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, newPreconditionError(err)
 	}
 
 	return decodeCalendarObjectList(ms)
 }
 
+// QueryFreeBusy asks the server for the busy periods of a calendar between
+// start and end, using the free-busy-query REPORT described in RFC 4791
+// section 7.10.
+func (c *Client) QueryFreeBusy(ctx context.Context, calendar string, start, end time.Time) (*FreeBusy, error) {
+	if start.IsZero() || end.IsZero() || !end.After(start) {
+		return nil, fmt.Errorf("caldav: free-busy query requires a start before its end")
+	}
+
+	query := freeBusyQuery{
+		TimeRange: timeRange{
+			Start: dateWithUTCTime(start),
+			End:   dateWithUTCTime(end),
+		},
+	}
+	req, err := c.ic.NewXMLRequest("REPORT", calendar, &query)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Depth", "1")
+	req.Header.Set("Accept", ical.MIMEType)
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, newPreconditionError(err)
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(mediaType, ical.MIMEType) {
+		return nil, fmt.Errorf("caldav: expected Content-Type %q, got %q", ical.MIMEType, mediaType)
+	}
+
+	cal, err := ical.NewDecoder(resp.Body).Decode()
+	if err != nil {
+		return nil, err
+	}
+	return decodeFreeBusy(cal)
+}
+
 func (c *Client) MultiGetCalendar(ctx context.Context, path string, multiGet *CalendarMultiGet) ([]CalendarObject, error) {
 	propReq, err := encodeCalendarReq(&multiGet.CompRequest)
 	if err != nil {
@@ -269,7 +568,7 @@ func (c *Client) MultiGetCalendar(ctx context.Context, path string, multiGet *Ca
 
 	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, newPreconditionError(err)
 	}
 
 	return decodeCalendarObjectList(ms)
@@ -373,8 +672,18 @@ func (c *Client) GetCalendarObject(ctx context.Context, path string) (*CalendarO
 	return co, nil
 }
 
-func (c *Client) PutCalendarObject(ctx context.Context, path string, cal *ical.Calendar) (*CalendarObject, error) {
-	// TODO: add support for If-None-Match and If-Match
+// PutCalendarObject stores a calendar object at path. If opts is nil, any
+// existing object is overwritten.
+//
+// opts.IfNoneMatch can be set to webdav.ConditionalMatchAny to only create a
+// new object, and opts.IfMatch to webdav.ConditionalMatchETag to only update
+// an object which hasn't changed since it was fetched. If the condition fails,
+// a *ConflictError is returned. If the server rejects the object itself, for
+// instance because its UID is already used, a *PreconditionError is returned.
+func (c *Client) PutCalendarObject(ctx context.Context, path string, cal *ical.Calendar, opts *PutCalendarObjectOptions) (*CalendarObject, error) {
+	if opts == nil {
+		opts = new(PutCalendarObjectOptions)
+	}
 
 	// TODO: some servers want a Content-Length header, so we can't stream the
 	// request body here. See the Radicale issue:
@@ -390,10 +699,16 @@ func (c *Client) PutCalendarObject(ctx context.Context, path string, cal *ical.C
 		return nil, err
 	}
 	req.Header.Set("Content-Type", ical.MIMEType)
+	if opts.IfNoneMatch.IsSet() {
+		req.Header.Set("If-None-Match", string(opts.IfNoneMatch))
+	}
+	if opts.IfMatch.IsSet() {
+		req.Header.Set("If-Match", string(opts.IfMatch))
+	}
 
 	resp, err := c.ic.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, newConflictError(path, newPreconditionError(err))
 	}
 	resp.Body.Close()
 
@@ -403,3 +718,74 @@ func (c *Client) PutCalendarObject(ctx context.Context, path string, cal *ical.C
 	}
 	return co, nil
 }
+
+// SyncCollection performs a collection synchronization operation on the
+// specified resource, as defined in RFC 6578.
+func (c *Client) SyncCollection(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	var limit *internal.Limit
+	if query.Limit > 0 {
+		limit = &internal.Limit{NResults: uint(query.Limit)}
+	}
+
+	propReq, err := encodeCalendarReq(&query.CompRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := c.ic.SyncCollection(ctx, path, query.SyncToken, internal.DepthOne, limit, propReq)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &SyncResponse{SyncToken: ms.SyncToken}
+	for _, resp := range ms.Responses {
+		p, err := resp.Path()
+		if err != nil {
+			if err, ok := err.(*internal.HTTPError); ok && err.Code == http.StatusNotFound {
+				ret.Deleted = append(ret.Deleted, p)
+				continue
+			} else if ok && err.Code == http.StatusInsufficientStorage && (p == path || path == fmt.Sprintf("%s/", p)) {
+				ret.Truncated = true
+				continue
+			}
+			return nil, err
+		}
+
+		if p == path || path == fmt.Sprintf("%s/", p) {
+			continue
+		}
+
+		var getLastMod internal.GetLastModified
+		if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
+		var getETag internal.GetETag
+		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
+		o := CalendarObject{
+			Path:    p,
+			ModTime: time.Time(getLastMod.LastModified),
+			ETag:    string(getETag.ETag),
+		}
+
+		// Servers may inline the calendar data in the sync response, saving
+		// a round-trip
+		var calData calendarDataResp
+		if err := resp.DecodeProp(&calData); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		} else if err == nil && len(calData.Data) > 0 {
+			data, err := ical.NewDecoder(bytes.NewReader(calData.Data)).Decode()
+			if err != nil {
+				return nil, err
+			}
+			o.Data = data
+		}
+
+		ret.Updated = append(ret.Updated, o)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,800 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	webdav "github.com/trvita/caldav-client-yandex"
+	"github.com/trvita/caldav-client-yandex/internal"
+	"github.com/trvita/go-ical"
+)
+
+const syncCollectionResponse = `<?xml version="1.0" encoding="utf-8" ?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:response>
+    <d:href>/user/calendars/a/event1.ics</d:href>
+    <d:propstat>
+      <d:prop>
+        <d:getetag>"00001-abcd1"</d:getetag>
+        <c:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:event1@example.com
+DTSTAMP:20060206T001102Z
+DTSTART:20060102T100000Z
+SUMMARY:Event #1
+END:VEVENT
+END:VCALENDAR
+</c:calendar-data>
+      </d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+  <d:response>
+    <d:href>/user/calendars/a/event2.ics</d:href>
+    <d:status>HTTP/1.1 404 Not Found</d:status>
+  </d:response>
+  <d:sync-token>http://example.com/ns/sync/1234</d:sync-token>
+</d:multistatus>`
+
+func TestClientSyncCollection(t *testing.T) {
+	var reqBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "REPORT" {
+			t.Errorf("Unexpected method %q, expected REPORT", r.Method)
+		}
+		b, _ := io.ReadAll(r.Body)
+		reqBody = string(b)
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, syncCollectionResponse)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	resp, err := client.SyncCollection(context.Background(), "/user/calendars/a/", &SyncQuery{
+		CompRequest: CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+		SyncToken:   "http://example.com/ns/sync/1233",
+	})
+	if err != nil {
+		t.Fatalf("SyncCollection() = %v", err)
+	}
+
+	if !strings.Contains(reqBody, "<sync-token>http://example.com/ns/sync/1233</sync-token>") {
+		t.Errorf("Sync token not sent in request:\n%s", reqBody)
+	}
+	if resp.SyncToken != "http://example.com/ns/sync/1234" {
+		t.Errorf("SyncToken = %q, expected %q", resp.SyncToken, "http://example.com/ns/sync/1234")
+	}
+	if len(resp.Updated) != 1 {
+		t.Fatalf("Found %d updated objects, expected 1", len(resp.Updated))
+	}
+	co := resp.Updated[0]
+	if co.Path != "/user/calendars/a/event1.ics" || co.ETag != "00001-abcd1" {
+		t.Errorf("Unexpected updated object: %+v", co)
+	}
+	if co.Data == nil || len(co.Data.Events()) != 1 {
+		t.Errorf("Calendar data not decoded for updated object: %+v", co)
+	}
+	if len(resp.Deleted) != 1 || resp.Deleted[0] != "/user/calendars/a/event2.ics" {
+		t.Errorf("Deleted = %v, expected [/user/calendars/a/event2.ics]", resp.Deleted)
+	}
+}
+
+func TestEncodeCompFilterTimeRange(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	for _, tc := range []struct {
+		start, end time.Time
+		want       string
+	}{
+		{
+			start: time.Date(2006, 1, 4, 3, 0, 0, 0, loc),
+			end:   time.Date(2006, 1, 5, 3, 0, 0, 0, loc),
+			want:  `<time-range xmlns="urn:ietf:params:xml:ns:caldav" start="20060104T000000Z" end="20060105T000000Z"></time-range>`,
+		},
+		{
+			end:  time.Date(2006, 1, 5, 0, 0, 0, 0, time.UTC),
+			want: `<time-range xmlns="urn:ietf:params:xml:ns:caldav" end="20060105T000000Z"></time-range>`,
+		},
+	} {
+		el, err := encodeCompFilter(&CompFilter{Name: "VEVENT", Start: tc.start, End: tc.end})
+		if err != nil {
+			t.Fatalf("encodeCompFilter() = %v", err)
+		}
+		b, err := xml.Marshal(el)
+		if err != nil {
+			t.Fatalf("xml.Marshal() = %v", err)
+		}
+		if !strings.Contains(string(b), tc.want) {
+			t.Errorf("encodeCompFilter() = %s, expected it to contain %s", b, tc.want)
+		}
+	}
+}
+
+func TestClientQueryCalendarPropFilter(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	for _, summary := range []string{"Team meeting", "Lunch"} {
+		cal := newTestCalendar(summary, summary, start)
+		cal.Events()[0].Props.Add(&ical.Prop{
+			Name:   ical.PropAttendee,
+			Params: ical.Params{ical.ParamParticipationStatus: []string{"ACCEPTED"}},
+			Value:  "mailto:alice@example.com",
+		})
+		if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/a/"+strings.ReplaceAll(summary, " ", "")+".ics", cal, nil); err != nil {
+			t.Fatalf("PutCalendarObject() = %v", err)
+		}
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		props []PropFilter
+		want  []string
+	}{
+		{
+			name:  "text-match",
+			props: []PropFilter{{Name: ical.PropSummary, TextMatch: &TextMatch{Text: "meeting"}}},
+			want:  []string{"/user/calendars/a/Teammeeting.ics"},
+		},
+		{
+			name:  "negated text-match",
+			props: []PropFilter{{Name: ical.PropSummary, TextMatch: &TextMatch{Text: "meeting", NegateCondition: true}}},
+			want:  []string{"/user/calendars/a/Lunch.ics"},
+		},
+		{
+			name:  "is-not-defined",
+			props: []PropFilter{{Name: ical.PropLocation, IsNotDefined: true}},
+			want:  []string{"/user/calendars/a/Lunch.ics", "/user/calendars/a/Teammeeting.ics"},
+		},
+		{
+			name: "param-filter",
+			props: []PropFilter{{
+				Name: ical.PropAttendee,
+				ParamFilter: []ParamFilter{{
+					Name:      ical.ParamParticipationStatus,
+					TextMatch: &TextMatch{Text: "DECLINED"},
+				}},
+			}},
+			want: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cos, err := client.QueryCalendar(ctx, "/user/calendars/a/", &CalendarQuery{
+				CompFilter: CompFilter{
+					Name:  "VCALENDAR",
+					Comps: []CompFilter{{Name: "VEVENT", Props: tc.props}},
+				},
+			})
+			if err != nil {
+				t.Fatalf("QueryCalendar() = %v", err)
+			}
+			var got []string
+			for _, co := range cos {
+				got = append(got, co.Path)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("QueryCalendar() = %v, expected %v", got, tc.want)
+			}
+		})
+	}
+
+	_, err = client.QueryCalendar(ctx, "/user/calendars/a/", &CalendarQuery{
+		CompFilter: CompFilter{Name: "VCALENDAR", IsNotDefined: true, Comps: []CompFilter{{Name: "VEVENT"}}},
+	})
+	if err == nil {
+		t.Errorf("QueryCalendar() with invalid is-not-defined filter succeeded")
+	}
+}
+
+func TestClientPutCalendarObjectConditional(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	const path = "/user/calendars/a/event.ics"
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	createOnly := &PutCalendarObjectOptions{IfNoneMatch: webdav.ConditionalMatchAny}
+
+	co, err := client.PutCalendarObject(ctx, path, newTestCalendar("event", "Meeting", start), createOnly)
+	if err != nil {
+		t.Fatalf("PutCalendarObject(create) = %v", err)
+	}
+	if co.ETag == "" {
+		t.Fatalf("PutCalendarObject(create) returned no ETag")
+	}
+
+	_, err = client.PutCalendarObject(ctx, path, newTestCalendar("event", "Meeting", start), createOnly)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("PutCalendarObject(create existing) = %v, want ConflictError", err)
+	} else if conflictErr.Path != path {
+		t.Errorf("ConflictError.Path = %q, want %q", conflictErr.Path, path)
+	}
+
+	update := &PutCalendarObjectOptions{IfMatch: webdav.ConditionalMatchETag(co.ETag)}
+	updated, err := client.PutCalendarObject(ctx, path, newTestCalendar("event", "Meeting (moved)", start), update)
+	if err != nil {
+		t.Fatalf("PutCalendarObject(update) = %v", err)
+	}
+
+	// The first ETag is now stale
+	_, err = client.PutCalendarObject(ctx, path, newTestCalendar("event", "Meeting (cancelled)", start), update)
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("PutCalendarObject(stale update) = %v, want ConflictError", err)
+	}
+
+	got, err := client.GetCalendarObject(ctx, path)
+	if err != nil {
+		t.Fatalf("GetCalendarObject() = %v", err)
+	}
+	if got.ETag != updated.ETag {
+		t.Errorf("ETag = %q, want %q", got.ETag, updated.ETag)
+	}
+	if summary, _ := got.Data.Events()[0].Props.Text(ical.PropSummary); summary != "Meeting (moved)" {
+		t.Errorf("SUMMARY = %q, want %q", summary, "Meeting (moved)")
+	}
+
+	_, err = client.PutCalendarObject(ctx, "/user/calendars/a/missing.ics", newTestCalendar("missing", "Missing", start), &PutCalendarObjectOptions{
+		IfMatch: webdav.ConditionalMatchAny,
+	})
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("PutCalendarObject(update missing) = %v, want ConflictError", err)
+	}
+}
+
+func TestClientCreateCalendar(t *testing.T) {
+	ctx := context.Background()
+
+	for _, mkcalendar := range []bool{true, false} {
+		b := NewMemoryBackend("/user/", "/user/calendars/")
+		h := &Handler{Backend: b}
+		var methods []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			methods = append(methods, r.Method)
+			if r.Method == "MKCALENDAR" && !mkcalendar {
+				http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+				return
+			}
+			h.ServeHTTP(w, r)
+		}))
+		defer ts.Close()
+
+		client, err := NewClient(nil, ts.URL)
+		if err != nil {
+			t.Fatalf("NewClient() = %v", err)
+		}
+
+		want := Calendar{
+			Path:                  "/user/calendars/work/",
+			Name:                  "Work",
+			Description:           "Meetings and deadlines",
+			Color:                 "#FF0000",
+			Timezone:              testTimezone,
+			SupportedComponentSet: []string{ical.CompEvent, ical.CompToDo},
+		}
+		if err := client.CreateCalendar(ctx, &want); err != nil {
+			t.Fatalf("CreateCalendar() = %v", err)
+		}
+		wantMethods := []string{"MKCALENDAR"}
+		if !mkcalendar {
+			// The server is probed to tell an unsupported method apart from
+			// an existing calendar
+			wantMethods = append(wantMethods, "PROPFIND", "MKCOL")
+		}
+		if !reflect.DeepEqual(methods, wantMethods) {
+			t.Errorf("methods = %v, want %v", methods, wantMethods)
+		}
+
+		calendars, err := client.FindCalendars(ctx, "/user/calendars/")
+		if err != nil {
+			t.Fatalf("FindCalendars() = %v", err)
+		}
+		if len(calendars) != 1 || !reflect.DeepEqual(calendars[0], want) {
+			t.Errorf("FindCalendars() = %+v, want %+v", calendars, want)
+		}
+
+		methods = nil
+		if err := client.CreateCalendar(ctx, &want); httpErrorCode(err) != http.StatusMethodNotAllowed {
+			t.Errorf("CreateCalendar() on an existing calendar = %v, want 405", err)
+		}
+		// The 405 reply must not be mistaken for missing MKCALENDAR support
+		for _, method := range methods {
+			if method == "MKCOL" {
+				t.Errorf("CreateCalendar() on an existing calendar fell back to MKCOL")
+			}
+		}
+	}
+}
+
+func TestClientCreateCalendarExisting(t *testing.T) {
+	var methods []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8" ?>
+<d:error xmlns:d="DAV:"><d:resource-must-be-null/></d:error>`)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	err = client.CreateCalendar(context.Background(), &Calendar{Path: "/user/calendars/work/"})
+	if httpErrorCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("CreateCalendar() = %v, want 405", err)
+	}
+	if want := []string{"MKCALENDAR"}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("methods = %v, want %v", methods, want)
+	}
+}
+
+const propPatchFailedResponse = `<?xml version="1.0" encoding="utf-8" ?>
+<d:multistatus xmlns:d="DAV:" xmlns:a="http://apple.com/ns/ical/">
+  <d:response>
+    <d:href>/user/calendars/a/</d:href>
+    <d:propstat>
+      <d:prop><a:calendar-color/></d:prop>
+      <d:status>HTTP/1.1 403 Forbidden</d:status>
+      <d:responsedescription>Invalid color</d:responsedescription>
+    </d:propstat>
+    <d:propstat>
+      <d:prop><d:displayname/></d:prop>
+      <d:status>HTTP/1.1 424 Failed Dependency</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`
+
+func TestClientUpdateCalendar(t *testing.T) {
+	var got internal.PropertyUpdate
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPPATCH" {
+			t.Errorf("method = %q, want PROPPATCH", r.Method)
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, propPatchFailedResponse)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	name, color, desc := "Work", "not a color", ""
+	statuses, err := client.UpdateCalendar(context.Background(), "/user/calendars/a/", &CalendarUpdate{
+		Name:        &name,
+		Color:       &color,
+		Description: &desc,
+	})
+	if err == nil || !strings.Contains(err.Error(), "calendar-color") || !strings.Contains(err.Error(), "Invalid color") {
+		t.Errorf("UpdateCalendar() error = %v, want calendar-color failure", err)
+	}
+
+	if len(got.Set) != 1 || len(got.Remove) != 1 {
+		t.Fatalf("request has %v set and %v remove elements, want 1 and 1", len(got.Set), len(got.Remove))
+	}
+	var dispName internal.DisplayName
+	if err := got.Set[0].Prop.Decode(&dispName); err != nil || dispName.Name != name {
+		t.Errorf("set displayname = %q, %v, want %q", dispName.Name, err, name)
+	}
+	var gotColor calendarColor
+	if err := got.Set[0].Prop.Decode(&gotColor); err != nil || gotColor.Color != color {
+		t.Errorf("set calendar-color = %q, %v, want %q", gotColor.Color, err, color)
+	}
+	if got.Remove[0].Prop.Get(calendarDescriptionName) == nil {
+		t.Errorf("calendar-description isn't removed")
+	}
+
+	want := map[xml.Name]int{
+		calendarColorName:        http.StatusForbidden,
+		internal.DisplayNameName: http.StatusFailedDependency,
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("got %v statuses, want %v", len(statuses), len(want))
+	}
+	for _, status := range statuses {
+		if status.Code != want[status.Name] || status.Err == nil {
+			t.Errorf("status of %v = %v, %v, want %v", status.Name.Local, status.Code, status.Err, want[status.Name])
+		}
+	}
+}
+
+const testTimezone = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTIMEZONE
+TZID:Europe/Moscow
+BEGIN:STANDARD
+DTSTART:19700101T000000
+TZOFFSETFROM:+0300
+TZOFFSETTO:+0300
+TZNAME:MSK
+END:STANDARD
+END:VTIMEZONE
+END:VCALENDAR
+`
+
+func TestClientUpdateCalendarRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/", Name: "A", Description: "Old"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	name, color, desc, tz := "Work", "#FF0000", "", testTimezone
+	statuses, err := client.UpdateCalendar(ctx, "/user/calendars/a/", &CalendarUpdate{
+		Name:        &name,
+		Description: &desc,
+		Color:       &color,
+		Timezone:    &tz,
+	})
+	if err != nil {
+		t.Fatalf("UpdateCalendar() = %v", err)
+	}
+	if len(statuses) != 4 {
+		t.Errorf("UpdateCalendar() returned %v statuses, want 4", len(statuses))
+	}
+	for _, status := range statuses {
+		if status.Code != http.StatusOK {
+			t.Errorf("status of %v = %v, want 200", status.Name.Local, status.Code)
+		}
+	}
+
+	want := Calendar{Path: "/user/calendars/a/", Name: name, Color: color, Timezone: tz}
+	cal, err := b.GetCalendar(ctx, "/user/calendars/a/")
+	if err != nil {
+		t.Fatalf("GetCalendar() = %v", err)
+	}
+	if !reflect.DeepEqual(*cal, want) {
+		t.Errorf("calendar = %+v, want %+v", cal, want)
+	}
+
+	// An invalid timezone makes the whole update fail
+	newName, badTZ := "Home", "BEGIN:VCALENDAR\nEND:VCALENDAR\n"
+	statuses, err = client.UpdateCalendar(ctx, "/user/calendars/a/", &CalendarUpdate{Name: &newName, Timezone: &badTZ})
+	if err == nil || !strings.Contains(err.Error(), "calendar-timezone") {
+		t.Errorf("UpdateCalendar() with invalid timezone = %v, want calendar-timezone failure", err)
+	}
+	wantCodes := map[string]int{"displayname": http.StatusFailedDependency, "calendar-timezone": http.StatusConflict}
+	for _, status := range statuses {
+		if status.Code != wantCodes[status.Name.Local] {
+			t.Errorf("status of %v = %v, want %v", status.Name.Local, status.Code, wantCodes[status.Name.Local])
+		}
+	}
+	if cal, _ := b.GetCalendar(ctx, "/user/calendars/a/"); cal.Name != name {
+		t.Errorf("calendar name = %q after failed update, want %q", cal.Name, name)
+	}
+
+	// Only calendars can be updated
+	_, err = client.UpdateCalendar(ctx, "/user/calendars/", &CalendarUpdate{Name: &newName})
+	if err == nil {
+		t.Errorf("UpdateCalendar() on home set succeeded")
+	}
+}
+
+func TestClientQueryCalendarExpand(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/a/standup.ics", decodeTestCalendar(t, recurringEvent), nil); err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	start, end := toDate(t, "20240102T000000Z"), toDate(t, "20240104T000000Z")
+	cos, err := client.QueryCalendar(ctx, "/user/calendars/a/", &CalendarQuery{
+		CompRequest: CalendarCompRequest{
+			Name:     "VCALENDAR",
+			AllProps: true,
+			AllComps: true,
+			Expand:   &CalendarExpandRequest{Start: start, End: end},
+		},
+		CompFilter: CompFilter{
+			Name:  "VCALENDAR",
+			Comps: []CompFilter{{Name: "VEVENT", Start: start, End: end}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryCalendar() = %v", err)
+	}
+	if len(cos) != 1 {
+		t.Fatalf("QueryCalendar() returned %v objects, want 1", len(cos))
+	}
+
+	got := instancesOf(t, cos[0].Data)
+	want := []testInstance{
+		{"Standup (moved)", "20240102T090000Z", "20240102T070000Z"},
+		{"Standup", "20240103T070000Z", "20240103T070000Z"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expanded instances = %+v, want %+v", got, want)
+	}
+
+	_, err = client.QueryCalendar(ctx, "/user/calendars/a/", &CalendarQuery{
+		CompRequest: CalendarCompRequest{Name: "VCALENDAR", Expand: &CalendarExpandRequest{Start: start}},
+		CompFilter:  CompFilter{Name: "VCALENDAR"},
+	})
+	if err == nil {
+		t.Errorf("QueryCalendar() with an open-ended expand succeeded")
+	}
+}
+
+func TestClientQueryFreeBusy(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/a/standup.ics", decodeTestCalendar(t, recurringEvent), nil); err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+	// Calendar objects can only hold a single UID
+	busy := decodeTestCalendar(t, busyEvents)
+	for i, event := range busy.Children {
+		cal := ical.NewCalendar()
+		cal.Props = busy.Props
+		cal.Children = []*ical.Component{event}
+		if _, _, err := b.PutCalendarObject(ctx, fmt.Sprintf("/user/calendars/a/busy-%d.ics", i), cal, nil); err != nil {
+			t.Fatalf("PutCalendarObject() = %v", err)
+		}
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	start, end := toDate(t, "20240103T000000Z"), toDate(t, "20240106T000000Z")
+	fb, err := client.QueryFreeBusy(ctx, "/user/calendars/a/", start, end)
+	if err != nil {
+		t.Fatalf("QueryFreeBusy() = %v", err)
+	}
+
+	want := &FreeBusy{
+		Start: start,
+		End:   end,
+		Busy: []FreeBusyPeriod{
+			{toDate(t, "20240103T070000Z"), toDate(t, "20240103T080000Z"), FreeBusyBusy},
+			{toDate(t, "20240103T100000Z"), toDate(t, "20240103T110000Z"), FreeBusyTentative},
+			{toDate(t, "20240103T230000Z"), toDate(t, "20240104T010000Z"), FreeBusyBusy},
+			{toDate(t, "20240105T080000Z"), toDate(t, "20240105T081500Z"), FreeBusyBusy},
+		},
+	}
+	if !reflect.DeepEqual(fb, want) {
+		t.Errorf("QueryFreeBusy() = %+v, want %+v", fb, want)
+	}
+
+	if _, err := client.QueryFreeBusy(ctx, "/user/calendars/a/", end, start); err == nil {
+		t.Errorf("QueryFreeBusy() with an inverted range succeeded")
+	}
+}
+
+const eventWithAttachment = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:offsite@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240110T090000Z
+DTEND:20240110T170000Z
+SUMMARY:Offsite
+DESCRIPTION:Agenda and travel details
+ATTACH;FMTTYPE=application/pdf:https://example.com/agenda.pdf
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT1H
+DESCRIPTION:Offsite
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestClientQueryCalendarPartial(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/a/offsite.ics", decodeTestCalendar(t, eventWithAttachment), nil); err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	cos, err := client.QueryCalendar(ctx, "/user/calendars/a/", &CalendarQuery{
+		CompRequest: CalendarCompRequest{
+			Name:  "VCALENDAR",
+			Props: []string{"VERSION"},
+			Comps: []CalendarCompRequest{{
+				Name:  "VEVENT",
+				Props: []string{"SUMMARY", "DTSTART"},
+			}},
+		},
+		CompFilter: CompFilter{
+			Name:  "VCALENDAR",
+			Comps: []CompFilter{{Name: "VEVENT"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryCalendar() = %v", err)
+	}
+	if len(cos) != 1 {
+		t.Fatalf("QueryCalendar() returned %v objects, want 1", len(cos))
+	}
+
+	events := cos[0].Data.Events()
+	if len(events) != 1 {
+		t.Fatalf("calendar-data has %v events, want 1", len(events))
+	}
+	event := events[0]
+	for _, name := range []string{"SUMMARY", "DTSTART", "UID", "DTSTAMP"} {
+		if event.Props.Get(name) == nil {
+			t.Errorf("calendar-data is missing %v", name)
+		}
+	}
+	for _, name := range []string{"DESCRIPTION", "ATTACH", "DTEND"} {
+		if event.Props.Get(name) != nil {
+			t.Errorf("calendar-data contains unrequested %v", name)
+		}
+	}
+	if len(event.Children) != 0 {
+		t.Errorf("calendar-data contains unrequested %v components", len(event.Children))
+	}
+}
+
+func TestClientPutCalendarObjectPrecondition(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8" ?>
+<D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <C:no-uid-conflict><D:href>/user/calendars/a/existing.ics</D:href></C:no-uid-conflict>
+</D:error>`)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	_, err = client.PutCalendarObject(context.Background(), "/user/calendars/a/new.ics", newTestCalendar("event", "Meeting", start), nil)
+	var precondErr *PreconditionError
+	if !errors.As(err, &precondErr) {
+		t.Fatalf("PutCalendarObject() = %v, want PreconditionError", err)
+	}
+	if precondErr.Precondition != PreconditionNoUIDConflict {
+		t.Errorf("PreconditionError.Precondition = %q, want %q", precondErr.Precondition, PreconditionNoUIDConflict)
+	}
+	if precondErr.Href != "/user/calendars/a/existing.ics" {
+		t.Errorf("PreconditionError.Href = %q, want %q", precondErr.Href, "/user/calendars/a/existing.ics")
+	}
+	var httpErr *internal.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusConflict {
+		t.Errorf("PutCalendarObject() = %v, want a wrapped 409 error", err)
+	}
+}
+
+func TestClientPutCalendarObjectInvalid(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	// Two events with different UIDs can't be stored in one object
+	cal := newTestCalendar("a", "Meeting", time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC))
+	other := newTestCalendar("b", "Lunch", time.Date(2006, 1, 4, 12, 0, 0, 0, time.UTC))
+	cal.Children = append(cal.Children, other.Children...)
+
+	_, err = client.PutCalendarObject(ctx, "/user/calendars/a/event.ics", cal, nil)
+	var precondErr *PreconditionError
+	if !errors.As(err, &precondErr) {
+		t.Fatalf("PutCalendarObject() = %v, want PreconditionError", err)
+	}
+	if precondErr.Precondition != PreconditionValidCalendarObjectResource {
+		t.Errorf("PreconditionError.Precondition = %q, want %q", precondErr.Precondition, PreconditionValidCalendarObjectResource)
+	}
+}
+
+func TestClientPutCalendarObjectUIDConflict(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	cal := newTestCalendar("a", "Meeting", time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC))
+	if _, err := client.PutCalendarObject(ctx, "/user/calendars/a/meeting.ics", cal, nil); err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+
+	_, err = client.PutCalendarObject(ctx, "/user/calendars/a/copy.ics", cal, nil)
+	var precondErr *PreconditionError
+	if !errors.As(err, &precondErr) {
+		t.Fatalf("PutCalendarObject() = %v, want PreconditionError", err)
+	}
+	if precondErr.Precondition != PreconditionNoUIDConflict {
+		t.Errorf("PreconditionError.Precondition = %q, want %q", precondErr.Precondition, PreconditionNoUIDConflict)
+	}
+	if precondErr.Href != "/user/calendars/a/meeting.ics" {
+		t.Errorf("PreconditionError.Href = %q, want %q", precondErr.Href, "/user/calendars/a/meeting.ics")
+	}
+}
@@ -8,18 +8,24 @@ import (
 	"github.com/trvita/caldav-client-yandex/internal"
 )
 
-const namespace = "urn:ietf:params:xml:ns:caldav"
+const (
+	namespace      = "urn:ietf:params:xml:ns:caldav"
+	appleNamespace = "http://apple.com/ns/ical/"
+)
 
 var (
 	calendarHomeSetName = xml.Name{namespace, "calendar-home-set"}
 
 	calendarDescriptionName           = xml.Name{namespace, "calendar-description"}
+	calendarTimezoneName              = xml.Name{namespace, "calendar-timezone"}
+	calendarColorName                 = xml.Name{appleNamespace, "calendar-color"}
 	supportedCalendarDataName         = xml.Name{namespace, "supported-calendar-data"}
 	supportedCalendarComponentSetName = xml.Name{namespace, "supported-calendar-component-set"}
 	maxResourceSizeName               = xml.Name{namespace, "max-resource-size"}
 
 	calendarQueryName    = xml.Name{namespace, "calendar-query"}
 	calendarMultigetName = xml.Name{namespace, "calendar-multiget"}
+	freeBusyQueryName    = xml.Name{namespace, "free-busy-query"}
 
 	calendarName     = xml.Name{namespace, "calendar"}
 	calendarDataName = xml.Name{namespace, "calendar-data"}
@@ -41,6 +47,19 @@ type calendarDescription struct {
 	Description string   `xml:",chardata"`
 }
 
+// https://tools.ietf.org/html/rfc4791#section-5.2.2
+type calendarTimezone struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-timezone"`
+	Data    string   `xml:",chardata"`
+}
+
+// calendar-color isn't standardized, but is supported by most servers and
+// clients.
+type calendarColor struct {
+	XMLName xml.Name `xml:"http://apple.com/ns/ical/ calendar-color"`
+	Color   string   `xml:",chardata"`
+}
+
 // https://tools.ietf.org/html/rfc4791#section-5.2.4
 type supportedCalendarData struct {
 	XMLName xml.Name           `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-data"`
@@ -85,6 +104,12 @@ type calendarMultiget struct {
 	PropName *struct{}       `xml:"DAV: propname,omitempty"`
 }
 
+// https://tools.ietf.org/html/rfc4791#section-9.11
+type freeBusyQuery struct {
+	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:caldav free-busy-query"`
+	TimeRange timeRange `xml:"time-range"`
+}
+
 // https://tools.ietf.org/html/rfc4791#section-9.7
 type filter struct {
 	XMLName    xml.Name   `xml:"urn:ietf:params:xml:ns:caldav filter"`
@@ -171,15 +196,51 @@ func (t *dateWithUTCTime) UnmarshalText(b []byte) error {
 }
 
 func (t *dateWithUTCTime) MarshalText() ([]byte, error) {
-	s := time.Time(*t).Format(dateWithUTCTimeLayout)
+	s := time.Time(*t).UTC().Format(dateWithUTCTimeLayout)
 	return []byte(s), nil
 }
 
+// MarshalXMLAttr omits the attribute for zero times, so that open-ended time
+// ranges can be expressed.
+func (t dateWithUTCTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if time.Time(t).IsZero() {
+		return xml.Attr{}, nil
+	}
+	b, err := t.MarshalText()
+	if err != nil {
+		return xml.Attr{}, err
+	}
+	return xml.Attr{Name: name, Value: string(b)}, nil
+}
+
 // Request variant of https://tools.ietf.org/html/rfc4791#section-9.6
 type calendarDataReq struct {
-	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
-	Comp    *comp    `xml:"comp,omitempty"`
-	// TODO: expand, limit-recurrence-set, limit-freebusy-set
+	XMLName            xml.Name            `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	Comp               *comp               `xml:"comp,omitempty"`
+	Expand             *expand             `xml:"expand,omitempty"`
+	LimitRecurrenceSet *limitRecurrenceSet `xml:"limit-recurrence-set,omitempty"`
+	LimitFreeBusySet   *limitFreeBusySet   `xml:"limit-freebusy-set,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.6.5
+type expand struct {
+	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav expand"`
+	Start   dateWithUTCTime `xml:"start,attr"`
+	End     dateWithUTCTime `xml:"end,attr"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.6.6
+type limitRecurrenceSet struct {
+	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav limit-recurrence-set"`
+	Start   dateWithUTCTime `xml:"start,attr"`
+	End     dateWithUTCTime `xml:"end,attr"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.6.7
+type limitFreeBusySet struct {
+	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav limit-freebusy-set"`
+	Start   dateWithUTCTime `xml:"start,attr"`
+	End     dateWithUTCTime `xml:"end,attr"`
 }
 
 // https://tools.ietf.org/html/rfc4791#section-9.6.1
@@ -210,7 +271,8 @@ type calendarDataResp struct {
 type reportReq struct {
 	Query    *calendarQuery
 	Multiget *calendarMultiget
-	// TODO: CALDAV:free-busy-query
+	Sync     *internal.SyncCollectionQuery
+	FreeBusy *freeBusyQuery
 }
 
 func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -222,6 +284,12 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	case calendarMultigetName:
 		r.Multiget = &calendarMultiget{}
 		v = r.Multiget
+	case internal.SyncCollectionName:
+		r.Sync = &internal.SyncCollectionQuery{}
+		v = r.Sync
+	case freeBusyQueryName:
+		r.FreeBusy = &freeBusyQuery{}
+		v = r.FreeBusy
 	default:
 		return fmt.Errorf("caldav: unsupported REPORT root %q %q", start.Name.Space, start.Name.Local)
 	}
@@ -229,9 +297,20 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return d.DecodeElement(v, &start)
 }
 
+// https://tools.ietf.org/html/rfc5689#section-5.1
 type mkcolReq struct {
-	XMLName      xml.Name              `xml:"DAV: mkcol"`
-	ResourceType internal.ResourceType `xml:"set>prop>resourcetype"`
-	DisplayName  string                `xml:"set>prop>displayname"`
-	// TODO this could theoretically contain all addressbook properties?
+	XMLName xml.Name      `xml:"DAV: mkcol"`
+	Set     *internal.Set `xml:"DAV: set,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.3
+type mkcalendarReq struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav mkcalendar"`
+	Set     *internal.Set `xml:"DAV: set,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-5.3.2.1
+type noUIDConflict struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav no-uid-conflict"`
+	Href    internal.Href `xml:"DAV: href"`
 }
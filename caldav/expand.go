@@ -0,0 +1,274 @@
+package caldav
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/trvita/go-ical"
+)
+
+// ExpandCalendar returns a copy of cal where recurring components are
+// replaced with their instances overlapping the time range, as described in
+// RFC 4791 section 9.6.5. Each instance has a RECURRENCE-ID property, and
+// date-time values are converted to UTC, so VTIMEZONE components are dropped.
+func ExpandCalendar(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	out := ical.NewCalendar()
+	for name, props := range cal.Props {
+		out.Props[name] = props
+	}
+
+	masters, overrides := splitRecurrences(cal.Children)
+	var instances []*ical.Component
+	for _, master := range masters {
+		l, err := expandComponent(master, overrides[uidOf(master)], start, end)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, l...)
+	}
+	for uid, l := range overrides {
+		if _, ok := masters[uid]; ok {
+			continue
+		}
+		// Overridden instances without a master component
+		for _, comp := range l {
+			ok, err := overlapsTimeRange(comp, start, end)
+			if err != nil {
+				return nil, err
+			} else if ok {
+				instances = append(instances, toUTC(comp))
+			}
+		}
+	}
+
+	// Keep the output stable, since masters and overrides are stored in maps
+	sort.Slice(instances, func(i, j int) bool {
+		ti, _ := instances[i].Props.DateTime(ical.PropDateTimeStart, time.UTC)
+		tj, _ := instances[j].Props.DateTime(ical.PropDateTimeStart, time.UTC)
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		if uidi, uidj := uidOf(instances[i]), uidOf(instances[j]); uidi != uidj {
+			return uidi < uidj
+		}
+		ri, _ := instances[i].Props.DateTime(ical.PropRecurrenceID, time.UTC)
+		rj, _ := instances[j].Props.DateTime(ical.PropRecurrenceID, time.UTC)
+		return ri.Before(rj)
+	})
+	out.Children = instances
+	return out, nil
+}
+
+// LimitRecurrenceSet returns a copy of cal where overridden instances of
+// recurring components not overlapping the time range are removed, as
+// described in RFC 4791 section 9.6.6.
+func LimitRecurrenceSet(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	out := ical.NewCalendar()
+	for name, props := range cal.Props {
+		out.Props[name] = props
+	}
+
+	for _, child := range cal.Children {
+		if child.Props.Get(ical.PropRecurrenceID) != nil {
+			ok, err := overlapsTimeRange(child, start, end)
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+		}
+		out.Children = append(out.Children, child)
+	}
+	return out, nil
+}
+
+func uidOf(comp *ical.Component) string {
+	uid, _ := comp.Props.Text(ical.PropUID)
+	return uid
+}
+
+// splitRecurrences sorts components into master components and overridden
+// instances, both indexed by UID. VTIMEZONE components are dropped.
+func splitRecurrences(children []*ical.Component) (masters map[string]*ical.Component, overrides map[string][]*ical.Component) {
+	masters = make(map[string]*ical.Component)
+	overrides = make(map[string][]*ical.Component)
+	for _, child := range children {
+		if child.Name == ical.CompTimezone {
+			continue
+		}
+		uid := uidOf(child)
+		if child.Props.Get(ical.PropRecurrenceID) != nil {
+			overrides[uid] = append(overrides[uid], child)
+		} else {
+			masters[uid] = child
+		}
+	}
+	return masters, overrides
+}
+
+func expandComponent(master *ical.Component, overrides []*ical.Component, start, end time.Time) ([]*ical.Component, error) {
+	rset, err := master.RecurrenceSet(time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []*ical.Component
+	overridden := make(map[time.Time]bool)
+	for _, comp := range overrides {
+		recurrenceID, err := comp.Props.DateTime(ical.PropRecurrenceID, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("caldav: invalid RECURRENCE-ID: %v", err)
+		}
+		overridden[recurrenceID.UTC()] = true
+
+		ok, err := overlapsTimeRange(comp, start, end)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			instances = append(instances, toUTC(comp))
+		}
+	}
+
+	if rset == nil {
+		ok, err := overlapsTimeRange(master, start, end)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			instances = append(instances, toUTC(master))
+		}
+		return instances, nil
+	}
+
+	dtstart := master.Props.Get(ical.PropDateTimeStart)
+	isDate := dtstart.ValueType() == ical.ValueDate
+	duration, err := componentDuration(master)
+	if err != nil {
+		return nil, err
+	}
+
+	// Instances starting before the range can still overlap it
+	for _, t := range rset.Between(start.Add(-duration), end, true) {
+		if overridden[t.UTC()] || !t.Before(end) {
+			continue
+		}
+		if duration > 0 && !t.Add(duration).After(start) {
+			continue
+		} else if duration == 0 && t.Before(start) {
+			continue
+		}
+
+		inst, err := shiftComponent(toUTC(master), t)
+		if err != nil {
+			return nil, err
+		}
+		inst.Props.Del(ical.PropRecurrenceRule)
+		inst.Props.Del(ical.PropRecurrenceDates)
+		inst.Props.Del(ical.PropExceptionDates)
+		if isDate {
+			inst.Props.SetDate(ical.PropRecurrenceID, t)
+		} else {
+			inst.Props.SetDateTime(ical.PropRecurrenceID, t.UTC())
+		}
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
+
+// componentDuration returns the duration of a component, or zero if it
+// doesn't have one. The duration of a VTODO spans from DTSTART to DUE.
+func componentDuration(comp *ical.Component) (time.Duration, error) {
+	switch comp.Name {
+	case ical.CompEvent:
+		event := ical.Event{comp}
+		start, err := event.DateTimeStart(time.UTC)
+		if err != nil {
+			return 0, err
+		}
+		end, err := event.DateTimeEnd(time.UTC)
+		if err != nil {
+			return 0, err
+		}
+		return end.Sub(start), nil
+	case ical.CompToDo:
+		if comp.Props.Get(ical.PropDue) == nil || comp.Props.Get(ical.PropDateTimeStart) == nil {
+			break
+		}
+		start, err := comp.Props.DateTime(ical.PropDateTimeStart, time.UTC)
+		if err != nil {
+			return 0, err
+		}
+		due, err := comp.Props.DateTime(ical.PropDue, time.UTC)
+		if err != nil {
+			return 0, err
+		}
+		return due.Sub(start), nil
+	}
+
+	if prop := comp.Props.Get(ical.PropDuration); prop != nil {
+		return prop.Duration()
+	}
+	return 0, nil
+}
+
+// overlapsTimeRange reports whether a single instance of a component
+// overlaps the time range.
+func overlapsTimeRange(comp *ical.Component, start, end time.Time) (bool, error) {
+	if comp.Props.Get(ical.PropDateTimeStart) == nil {
+		return true, nil
+	}
+	t, err := comp.Props.DateTime(ical.PropDateTimeStart, time.UTC)
+	if err != nil {
+		return false, err
+	}
+	duration, err := componentDuration(comp)
+	if err != nil {
+		return false, err
+	}
+	if duration > 0 {
+		return t.Before(end) && t.Add(duration).After(start), nil
+	}
+	return !t.Before(start) && t.Before(end), nil
+}
+
+var dateTimeProps = []string{
+	ical.PropDateTimeStart,
+	ical.PropDateTimeEnd,
+	ical.PropDue,
+	ical.PropRecurrenceID,
+	ical.PropExceptionDates,
+	ical.PropRecurrenceDates,
+}
+
+// toUTC returns a copy of comp where date-time properties are converted to
+// UTC. Child components such as VALARM are shared with comp.
+func toUTC(comp *ical.Component) *ical.Component {
+	out := ical.NewComponent(comp.Name)
+	out.Children = comp.Children
+	for name, props := range comp.Props {
+		out.Props[name] = append([]ical.Prop(nil), props...)
+	}
+
+	for _, name := range dateTimeProps {
+		for i := range out.Props[name] {
+			prop := &out.Props[name][i]
+			if prop.Params.Get(ical.PropTimezoneID) == "" || prop.ValueType() == ical.ValueDate {
+				continue
+			}
+			t, err := prop.DateTime(time.UTC)
+			if err != nil {
+				// Leave values we can't interpret untouched
+				continue
+			}
+			params := make(ical.Params, len(prop.Params))
+			for k, v := range prop.Params {
+				if k != ical.PropTimezoneID {
+					params[k] = v
+				}
+			}
+			prop.Params = params
+			prop.SetDateTime(t.UTC())
+		}
+	}
+	return out
+}
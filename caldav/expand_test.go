@@ -0,0 +1,250 @@
+package caldav
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trvita/go-ical"
+)
+
+const recurringEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTIMEZONE
+TZID:Europe/Moscow
+BEGIN:STANDARD
+DTSTART:19700101T000000
+TZOFFSETFROM:+0300
+TZOFFSETTO:+0300
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20060206T001121Z
+DTSTART;TZID=Europe/Moscow:20240101T100000
+DTEND;TZID=Europe/Moscow:20240101T101500
+RRULE:FREQ=DAILY;COUNT=5
+EXDATE;TZID=Europe/Moscow:20240104T100000
+SUMMARY:Standup
+END:VEVENT
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20060206T001121Z
+RECURRENCE-ID;TZID=Europe/Moscow:20240102T100000
+DTSTART;TZID=Europe/Moscow:20240102T120000
+DTEND;TZID=Europe/Moscow:20240102T121500
+SUMMARY:Standup (moved)
+END:VEVENT
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20060206T001121Z
+RECURRENCE-ID;TZID=Europe/Moscow:20240105T100000
+DTSTART;TZID=Europe/Moscow:20240105T110000
+DTEND;TZID=Europe/Moscow:20240105T111500
+SUMMARY:Standup (late)
+END:VEVENT
+END:VCALENDAR
+`
+
+func decodeTestCalendar(t *testing.T, s string) *ical.Calendar {
+	cal, err := ical.NewDecoder(strings.NewReader(s)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal
+}
+
+type testInstance struct {
+	summary, start, recurrenceID string
+}
+
+func instancesOf(t *testing.T, cal *ical.Calendar) []testInstance {
+	var l []testInstance
+	for _, child := range cal.Children {
+		if child.Name != ical.CompEvent {
+			t.Errorf("unexpected %v component", child.Name)
+			continue
+		}
+		if child.Props.Get(ical.PropRecurrenceRule) != nil {
+			t.Errorf("instance %v has an RRULE", child.Props.Get(ical.PropDateTimeStart).Value)
+		}
+		summary, _ := child.Props.Text(ical.PropSummary)
+		inst := testInstance{summary: summary, start: child.Props.Get(ical.PropDateTimeStart).Value}
+		if prop := child.Props.Get(ical.PropRecurrenceID); prop != nil {
+			inst.recurrenceID = prop.Value
+		}
+		l = append(l, inst)
+	}
+	return l
+}
+
+func TestExpandCalendar(t *testing.T) {
+	cal := decodeTestCalendar(t, recurringEvent)
+
+	for _, tc := range []struct {
+		name       string
+		start, end time.Time
+		want       []testInstance
+	}{
+		{
+			name:  "whole set",
+			start: toDate(t, "20240101T000000Z"),
+			end:   toDate(t, "20240201T000000Z"),
+			want: []testInstance{
+				{"Standup", "20240101T070000Z", "20240101T070000Z"},
+				{"Standup (moved)", "20240102T090000Z", "20240102T070000Z"},
+				{"Standup", "20240103T070000Z", "20240103T070000Z"},
+				{"Standup (late)", "20240105T080000Z", "20240105T070000Z"},
+			},
+		},
+		{
+			// The moved instance is outside the range, although its
+			// original start time isn't
+			name:  "moved out of range",
+			start: toDate(t, "20240102T060000Z"),
+			end:   toDate(t, "20240102T080000Z"),
+		},
+		{
+			name:  "overlapping the end of an instance",
+			start: toDate(t, "20240103T071000Z"),
+			end:   toDate(t, "20240103T080000Z"),
+			want: []testInstance{
+				{"Standup", "20240103T070000Z", "20240103T070000Z"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expanded, err := ExpandCalendar(cal, tc.start, tc.end)
+			if err != nil {
+				t.Fatalf("ExpandCalendar() = %v", err)
+			}
+			got := instancesOf(t, expanded)
+			if len(got) != len(tc.want) {
+				t.Fatalf("ExpandCalendar() = %+v, want %+v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("instance %v = %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+
+	if len(cal.Children) != 4 || cal.Children[1].Props.Get(ical.PropRecurrenceRule) == nil {
+		t.Errorf("ExpandCalendar() modified its input")
+	}
+}
+
+const recurringToDo = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTODO
+UID:report@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240101T090000Z
+DUE:20240101T170000Z
+RRULE:FREQ=WEEKLY;COUNT=3
+SUMMARY:Weekly report
+END:VTODO
+END:VCALENDAR
+`
+
+func TestExpandCalendarToDo(t *testing.T) {
+	cal := decodeTestCalendar(t, recurringToDo)
+
+	// The range only overlaps the second instance between DTSTART and DUE
+	expanded, err := ExpandCalendar(cal, toDate(t, "20240108T120000Z"), toDate(t, "20240108T130000Z"))
+	if err != nil {
+		t.Fatalf("ExpandCalendar() = %v", err)
+	}
+	if len(expanded.Children) != 1 {
+		t.Fatalf("ExpandCalendar() returned %v instances, want 1", len(expanded.Children))
+	}
+	inst := expanded.Children[0]
+	if inst.Name != ical.CompToDo || inst.Props.Get(ical.PropRecurrenceRule) != nil {
+		t.Errorf("instance = %v with RRULE %v, want a single VTODO", inst.Name, inst.Props.Get(ical.PropRecurrenceRule))
+	}
+	for name, want := range map[string]string{
+		ical.PropDateTimeStart: "20240108T090000Z",
+		ical.PropDue:           "20240108T170000Z",
+		ical.PropRecurrenceID:  "20240108T090000Z",
+	} {
+		if prop := inst.Props.Get(name); prop == nil || prop.Value != want {
+			t.Errorf("instance %v = %v, want %v", name, prop, want)
+		}
+	}
+}
+
+const concurrentEvents = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:b@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240101T090000Z
+DTEND:20240101T100000Z
+RRULE:FREQ=DAILY;COUNT=2
+SUMMARY:B
+END:VEVENT
+BEGIN:VEVENT
+UID:c@example.com
+DTSTAMP:20060206T001121Z
+RECURRENCE-ID:20240101T090000Z
+DTSTART:20240101T090000Z
+DTEND:20240101T100000Z
+SUMMARY:C (orphan)
+END:VEVENT
+BEGIN:VEVENT
+UID:a@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240101T090000Z
+DTEND:20240101T100000Z
+RRULE:FREQ=DAILY;COUNT=2
+SUMMARY:A
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestExpandCalendarOrder(t *testing.T) {
+	cal := decodeTestCalendar(t, concurrentEvents)
+	want := []testInstance{
+		{"A", "20240101T090000Z", "20240101T090000Z"},
+		{"B", "20240101T090000Z", "20240101T090000Z"},
+		{"C (orphan)", "20240101T090000Z", "20240101T090000Z"},
+		{"A", "20240102T090000Z", "20240102T090000Z"},
+		{"B", "20240102T090000Z", "20240102T090000Z"},
+	}
+
+	// Components are grouped in maps, make sure their order doesn't leak
+	for i := 0; i < 10; i++ {
+		expanded, err := ExpandCalendar(cal, toDate(t, "20240101T000000Z"), toDate(t, "20240103T000000Z"))
+		if err != nil {
+			t.Fatalf("ExpandCalendar() = %v", err)
+		}
+		got := instancesOf(t, expanded)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ExpandCalendar() = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestLimitRecurrenceSet(t *testing.T) {
+	cal := decodeTestCalendar(t, recurringEvent)
+
+	limited, err := LimitRecurrenceSet(cal, toDate(t, "20240105T000000Z"), toDate(t, "20240106T000000Z"))
+	if err != nil {
+		t.Fatalf("LimitRecurrenceSet() = %v", err)
+	}
+
+	var summaries []string
+	for _, child := range limited.Children {
+		summary, _ := child.Props.Text(ical.PropSummary)
+		summaries = append(summaries, child.Name+":"+summary)
+	}
+	want := "VTIMEZONE:,VEVENT:Standup,VEVENT:Standup (late)"
+	if got := strings.Join(summaries, ","); got != want {
+		t.Errorf("LimitRecurrenceSet() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,244 @@
+package caldav
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trvita/go-ical"
+)
+
+// FreeBusyType is the type of a busy period, as defined in RFC 5545 section
+// 3.2.9.
+type FreeBusyType string
+
+const (
+	FreeBusyBusy        FreeBusyType = "BUSY"
+	FreeBusyTentative   FreeBusyType = "BUSY-TENTATIVE"
+	FreeBusyUnavailable FreeBusyType = "BUSY-UNAVAILABLE"
+)
+
+// FreeBusyPeriod is a period of time during which a calendar is busy.
+type FreeBusyPeriod struct {
+	Start, End time.Time
+	Type       FreeBusyType
+}
+
+// FreeBusy is the result of a free-busy-query REPORT, described in RFC 4791
+// section 7.10. Busy periods are sorted by start time.
+type FreeBusy struct {
+	Start, End time.Time
+	Busy       []FreeBusyPeriod
+}
+
+// computeFreeBusy collects the busy periods between start and end from
+// the events in cos. Transparent and cancelled events are ignored, and
+// recurring events are expanded.
+func computeFreeBusy(cos []CalendarObject, start, end time.Time) (*FreeBusy, error) {
+	var periods []FreeBusyPeriod
+	for _, co := range cos {
+		cal, err := ExpandCalendar(co.Data, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range cal.Events() {
+			if transp, _ := event.Props.Text(ical.PropTransparency); strings.EqualFold(transp, "TRANSPARENT") {
+				continue
+			}
+
+			fbType := FreeBusyBusy
+			status, _ := event.Props.Text(ical.PropStatus)
+			switch strings.ToUpper(status) {
+			case "CANCELLED":
+				continue
+			case "TENTATIVE":
+				fbType = FreeBusyTentative
+			}
+
+			eventStart, err := event.DateTimeStart(time.UTC)
+			if err != nil {
+				return nil, err
+			}
+			eventEnd, err := event.DateTimeEnd(time.UTC)
+			if err != nil {
+				return nil, err
+			}
+			if eventStart.Before(start) {
+				eventStart = start
+			}
+			if eventEnd.After(end) {
+				eventEnd = end
+			}
+			if !eventEnd.After(eventStart) {
+				continue
+			}
+			periods = append(periods, FreeBusyPeriod{Start: eventStart, End: eventEnd, Type: fbType})
+		}
+	}
+
+	return &FreeBusy{Start: start, End: end, Busy: mergeFreeBusyPeriods(periods)}, nil
+}
+
+// mergeFreeBusyPeriods sorts periods and coalesces overlapping periods of
+// the same type.
+func mergeFreeBusyPeriods(periods []FreeBusyPeriod) []FreeBusyPeriod {
+	sort.Slice(periods, func(i, j int) bool {
+		if !periods[i].Start.Equal(periods[j].Start) {
+			return periods[i].Start.Before(periods[j].Start)
+		}
+		return periods[i].Type < periods[j].Type
+	})
+
+	var merged []FreeBusyPeriod
+	last := make(map[FreeBusyType]int)
+	for _, p := range periods {
+		if i, ok := last[p.Type]; ok && !p.Start.After(merged[i].End) {
+			if p.End.After(merged[i].End) {
+				merged[i].End = p.End
+			}
+			continue
+		}
+		last[p.Type] = len(merged)
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+func encodeFreeBusy(fb *FreeBusy) *ical.Calendar {
+	comp := ical.NewComponent(ical.CompFreeBusy)
+	comp.Props.SetText(ical.PropUID, uuid.NewString())
+	comp.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	comp.Props.SetDateTime(ical.PropDateTimeStart, fb.Start.UTC())
+	comp.Props.SetDateTime(ical.PropDateTimeEnd, fb.End.UTC())
+	for _, p := range fb.Busy {
+		prop := ical.NewProp(ical.PropFreeBusy)
+		prop.Params.Set(ical.ParamFreeBusyType, string(p.Type))
+		prop.SetValueType(ical.ValuePeriod)
+		prop.Value = p.Start.UTC().Format(dateWithUTCTimeLayout) + "/" + p.End.UTC().Format(dateWithUTCTimeLayout)
+		comp.Props.Add(prop)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//trvita//caldav-client-yandex//EN")
+	cal.Children = append(cal.Children, comp)
+	return cal
+}
+
+func decodeFreeBusy(cal *ical.Calendar) (*FreeBusy, error) {
+	var comp *ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompFreeBusy {
+			comp = child
+			break
+		}
+	}
+	if comp == nil {
+		return nil, fmt.Errorf("caldav: missing VFREEBUSY component in free-busy response")
+	}
+
+	var (
+		fb  FreeBusy
+		err error
+	)
+	if fb.Start, err = comp.Props.DateTime(ical.PropDateTimeStart, time.UTC); err != nil {
+		return nil, err
+	}
+	if fb.End, err = comp.Props.DateTime(ical.PropDateTimeEnd, time.UTC); err != nil {
+		return nil, err
+	}
+
+	for _, prop := range comp.Props[ical.PropFreeBusy] {
+		fbType := FreeBusyType(strings.ToUpper(prop.Params.Get(ical.ParamFreeBusyType)))
+		switch fbType {
+		case "":
+			fbType = FreeBusyBusy
+		case "FREE":
+			continue
+		}
+		for _, s := range strings.Split(prop.Value, ",") {
+			p, err := parseFreeBusyPeriod(s)
+			if err != nil {
+				return nil, err
+			}
+			p.Type = fbType
+			fb.Busy = append(fb.Busy, p)
+		}
+	}
+	sort.SliceStable(fb.Busy, func(i, j int) bool {
+		return fb.Busy[i].Start.Before(fb.Busy[j].Start)
+	})
+	return &fb, nil
+}
+
+// LimitFreeBusySet returns a copy of cal where the FREEBUSY periods of
+// VFREEBUSY components not overlapping the time range are removed, as
+// described in RFC 4791 section 9.6.7.
+func LimitFreeBusySet(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	out := ical.NewCalendar()
+	for name, props := range cal.Props {
+		out.Props[name] = props
+	}
+
+	for _, child := range cal.Children {
+		if child.Name != ical.CompFreeBusy {
+			out.Children = append(out.Children, child)
+			continue
+		}
+
+		comp := ical.NewComponent(child.Name)
+		comp.Children = child.Children
+		for name, props := range child.Props {
+			if name != ical.PropFreeBusy {
+				comp.Props[name] = props
+			}
+		}
+		for _, prop := range child.Props[ical.PropFreeBusy] {
+			var periods []string
+			for _, s := range strings.Split(prop.Value, ",") {
+				p, err := parseFreeBusyPeriod(s)
+				if err != nil {
+					return nil, err
+				}
+				if p.Start.Before(end) && p.End.After(start) {
+					periods = append(periods, s)
+				}
+			}
+			if len(periods) > 0 {
+				prop.Value = strings.Join(periods, ",")
+				comp.Props.Add(&prop)
+			}
+		}
+		out.Children = append(out.Children, comp)
+	}
+	return out, nil
+}
+
+// parseFreeBusyPeriod parses a UTC period, either explicit or with a
+// duration, as defined in RFC 5545 section 3.3.9.
+func parseFreeBusyPeriod(s string) (FreeBusyPeriod, error) {
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return FreeBusyPeriod{}, fmt.Errorf("caldav: invalid FREEBUSY period %q", s)
+	}
+	start, err := time.Parse(dateWithUTCTimeLayout, startStr)
+	if err != nil {
+		return FreeBusyPeriod{}, fmt.Errorf("caldav: invalid FREEBUSY period %q: %v", s, err)
+	}
+
+	var end time.Time
+	if strings.HasPrefix(endStr, "P") || strings.HasPrefix(endStr, "+P") {
+		prop := ical.NewProp(ical.PropDuration)
+		prop.Value = endStr
+		d, err := prop.Duration()
+		if err != nil {
+			return FreeBusyPeriod{}, fmt.Errorf("caldav: invalid FREEBUSY period %q: %v", s, err)
+		}
+		end = start.Add(d)
+	} else if end, err = time.Parse(dateWithUTCTimeLayout, endStr); err != nil {
+		return FreeBusyPeriod{}, fmt.Errorf("caldav: invalid FREEBUSY period %q: %v", s, err)
+	}
+	return FreeBusyPeriod{Start: start, End: end}, nil
+}
@@ -0,0 +1,145 @@
+package caldav
+
+import (
+	"reflect"
+	"testing"
+)
+
+const busyEvents = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:review@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240103T071000Z
+DTEND:20240103T080000Z
+SUMMARY:Review
+END:VEVENT
+BEGIN:VEVENT
+UID:lunch@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240102T120000Z
+DTEND:20240102T130000Z
+TRANSP:TRANSPARENT
+SUMMARY:Lunch
+END:VEVENT
+BEGIN:VEVENT
+UID:offsite@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240102T140000Z
+DTEND:20240102T150000Z
+STATUS:CANCELLED
+SUMMARY:Offsite
+END:VEVENT
+BEGIN:VEVENT
+UID:maybe@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240103T100000Z
+DURATION:PT1H
+STATUS:TENTATIVE
+SUMMARY:Maybe
+END:VEVENT
+BEGIN:VEVENT
+UID:release@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240103T230000Z
+DTEND:20240104T010000Z
+SUMMARY:Release
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestComputeFreeBusy(t *testing.T) {
+	cos := []CalendarObject{
+		{Path: "/user/calendars/a/standup.ics", Data: decodeTestCalendar(t, recurringEvent)},
+		{Path: "/user/calendars/a/busy.ics", Data: decodeTestCalendar(t, busyEvents)},
+	}
+	start, end := toDate(t, "20240101T000000Z"), toDate(t, "20240104T000000Z")
+
+	fb, err := computeFreeBusy(cos, start, end)
+	if err != nil {
+		t.Fatalf("computeFreeBusy() = %v", err)
+	}
+
+	want := &FreeBusy{
+		Start: start,
+		End:   end,
+		Busy: []FreeBusyPeriod{
+			{toDate(t, "20240101T070000Z"), toDate(t, "20240101T071500Z"), FreeBusyBusy},
+			{toDate(t, "20240102T090000Z"), toDate(t, "20240102T091500Z"), FreeBusyBusy},
+			{toDate(t, "20240103T070000Z"), toDate(t, "20240103T080000Z"), FreeBusyBusy},
+			{toDate(t, "20240103T100000Z"), toDate(t, "20240103T110000Z"), FreeBusyTentative},
+			{toDate(t, "20240103T230000Z"), end, FreeBusyBusy},
+		},
+	}
+	if !reflect.DeepEqual(fb, want) {
+		t.Errorf("computeFreeBusy() = %+v, want %+v", fb, want)
+	}
+
+	decoded, err := decodeFreeBusy(encodeFreeBusy(fb))
+	if err != nil {
+		t.Fatalf("decodeFreeBusy() = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("decodeFreeBusy(encodeFreeBusy()) = %+v, want %+v", decoded, want)
+	}
+}
+
+func TestDecodeFreeBusy(t *testing.T) {
+	cal := decodeTestCalendar(t, `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Server//EN
+BEGIN:VFREEBUSY
+DTSTAMP:20050125T090000Z
+DTSTART:20060104T140000Z
+DTEND:20060105T220000Z
+FREEBUSY;FBTYPE=BUSY-TENTATIVE:20060104T150000Z/PT1H
+FREEBUSY:20060105T170000Z/20060105T180000Z,20060104T190000Z/PT30M
+FREEBUSY;FBTYPE=FREE:20060104T200000Z/PT1H
+END:VFREEBUSY
+END:VCALENDAR
+`)
+
+	fb, err := decodeFreeBusy(cal)
+	if err != nil {
+		t.Fatalf("decodeFreeBusy() = %v", err)
+	}
+	want := &FreeBusy{
+		Start: toDate(t, "20060104T140000Z"),
+		End:   toDate(t, "20060105T220000Z"),
+		Busy: []FreeBusyPeriod{
+			{toDate(t, "20060104T150000Z"), toDate(t, "20060104T160000Z"), FreeBusyTentative},
+			{toDate(t, "20060104T190000Z"), toDate(t, "20060104T193000Z"), FreeBusyBusy},
+			{toDate(t, "20060105T170000Z"), toDate(t, "20060105T180000Z"), FreeBusyBusy},
+		},
+	}
+	if !reflect.DeepEqual(fb, want) {
+		t.Errorf("decodeFreeBusy() = %+v, want %+v", fb, want)
+	}
+}
+
+func TestLimitFreeBusySet(t *testing.T) {
+	cal := decodeTestCalendar(t, `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VFREEBUSY
+DTSTAMP:20060206T001121Z
+UID:freebusy@example.com
+FREEBUSY:20060104T150000Z/PT1H,20060104T190000Z/20060104T200000Z
+FREEBUSY;FBTYPE=BUSY-TENTATIVE:20060105T090000Z/PT1H
+END:VFREEBUSY
+END:VCALENDAR
+`)
+
+	limited, err := LimitFreeBusySet(cal, toDate(t, "20060104T180000Z"), toDate(t, "20060105T000000Z"))
+	if err != nil {
+		t.Fatalf("LimitFreeBusySet() = %v", err)
+	}
+	props := limited.Children[0].Props.Values("FREEBUSY")
+	if len(props) != 1 || props[0].Value != "20060104T190000Z/20060104T200000Z" {
+		t.Errorf("LimitFreeBusySet() kept FREEBUSY %+v, want the 19:00 period only", props)
+	}
+	if got := len(cal.Children[0].Props.Values("FREEBUSY")); got != 2 {
+		t.Errorf("LimitFreeBusySet() modified its input, %v FREEBUSY properties left", got)
+	}
+}
@@ -0,0 +1,384 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/trvita/caldav-client-yandex/internal"
+	"github.com/trvita/go-ical"
+)
+
+// localCalendarMetadataFile is the name of the sidecar file holding calendar
+// properties which can't be stored in the calendar objects themselves.
+const localCalendarMetadataFile = ".calendar.json"
+
+// LocalBackend is a Backend storing calendars in a local directory. Each
+// calendar is a sub-directory containing one .ics file per calendar object,
+// along with a metadata sidecar file.
+type LocalBackend struct {
+	dir           string
+	principalPath string
+	homeSetPath   string
+
+	// mu serializes writes, so that conditional requests and UID checks
+	// aren't racy within a single process
+	mu sync.RWMutex
+}
+
+type localCalendarMetadata struct {
+	Name                  string   `json:"name,omitempty"`
+	Description           string   `json:"description,omitempty"`
+	Color                 string   `json:"color,omitempty"`
+	Timezone              string   `json:"timezone,omitempty"`
+	MaxResourceSize       int64    `json:"maxResourceSize,omitempty"`
+	SupportedComponentSet []string `json:"supportedComponentSet,omitempty"`
+}
+
+var (
+	_ Backend               = (*LocalBackend)(nil)
+	_ CalendarUpdateBackend = (*LocalBackend)(nil)
+)
+
+// NewLocalBackend creates a new LocalBackend storing calendars in dir, and
+// serving them for the principal at principalPath, below homeSetPath.
+func NewLocalBackend(dir, principalPath, homeSetPath string) *LocalBackend {
+	return &LocalBackend{
+		dir:           dir,
+		principalPath: principalPath,
+		homeSetPath:   homeSetPath,
+	}
+}
+
+// calendarDir returns the name and local directory of the calendar at p.
+func (b *LocalBackend) calendarDir(p string) (name, dir string, err error) {
+	p = internal.CleanPath(p)
+	name = path.Base(p)
+	if path.Dir(p) != internal.CleanPath(b.homeSetPath) || !internal.IsValidLocalName(name) {
+		return "", "", internal.HTTPErrorf(http.StatusNotFound, "caldav: no calendar at %q", p)
+	}
+	return name, filepath.Join(b.dir, name), nil
+}
+
+// objectFile returns the path and local directory of the calendar containing
+// the calendar object at p, and the object's file name.
+func (b *LocalBackend) objectFile(p string) (calPath, dir, file string, err error) {
+	p = internal.CleanPath(p)
+	name, dir, err := b.calendarDir(path.Dir(p))
+	if err != nil {
+		return "", "", "", err
+	}
+	file = path.Base(p)
+	if !internal.IsValidLocalName(file) || path.Ext(file) != ".ics" {
+		return "", "", "", internal.HTTPErrorf(http.StatusNotFound, "caldav: no calendar object at %q", p)
+	}
+	return b.calendarPath(name), dir, file, nil
+}
+
+func (b *LocalBackend) calendarPath(name string) string {
+	return path.Join(internal.CleanPath(b.homeSetPath), name) + "/"
+}
+
+func (b *LocalBackend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	return b.principalPath, nil
+}
+
+func (b *LocalBackend) CalendarHomeSetPath(ctx context.Context) (string, error) {
+	return b.homeSetPath, nil
+}
+
+func (b *LocalBackend) readCalendar(name, dir string) (*Calendar, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	} else if !fi.IsDir() {
+		return nil, internal.HTTPErrorf(http.StatusNotFound, "caldav: no calendar at %q", b.calendarPath(name))
+	}
+
+	var md localCalendarMetadata
+	data, err := os.ReadFile(filepath.Join(dir, localCalendarMetadataFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &md); err != nil {
+			return nil, fmt.Errorf("caldav: failed to read metadata of calendar %q: %v", name, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, internal.ErrFromOS(err)
+	}
+
+	return &Calendar{
+		Path:                  b.calendarPath(name),
+		Name:                  md.Name,
+		Description:           md.Description,
+		Color:                 md.Color,
+		Timezone:              md.Timezone,
+		MaxResourceSize:       md.MaxResourceSize,
+		SupportedComponentSet: md.SupportedComponentSet,
+	}, nil
+}
+
+func writeCalendarMetadata(dir string, calendar *Calendar) error {
+	data, err := json.MarshalIndent(&localCalendarMetadata{
+		Name:                  calendar.Name,
+		Description:           calendar.Description,
+		Color:                 calendar.Color,
+		Timezone:              calendar.Timezone,
+		MaxResourceSize:       calendar.MaxResourceSize,
+		SupportedComponentSet: calendar.SupportedComponentSet,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(dir, localCalendarMetadataFile, data)
+}
+
+func (b *LocalBackend) CreateCalendar(ctx context.Context, calendar *Calendar) error {
+	name, dir, err := b.calendarDir(calendar.Path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return internal.ErrFromOS(err)
+	}
+	if err := os.Mkdir(dir, 0755); os.IsExist(err) {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "caldav: calendar %q already exists", b.calendarPath(name))
+	} else if err != nil {
+		return internal.ErrFromOS(err)
+	}
+	if err := writeCalendarMetadata(dir, calendar); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+func (b *LocalBackend) ListCalendars(ctx context.Context) ([]Calendar, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	entries, err := os.ReadDir(b.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+
+	var l []Calendar
+	for _, entry := range entries {
+		if !entry.IsDir() || !internal.IsValidLocalName(entry.Name()) {
+			continue
+		}
+		cal, err := b.readCalendar(entry.Name(), filepath.Join(b.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, *cal)
+	}
+	return l, nil
+}
+
+func (b *LocalBackend) GetCalendar(ctx context.Context, path string) (*Calendar, error) {
+	name, dir, err := b.calendarDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.readCalendar(name, dir)
+}
+
+func (b *LocalBackend) DeleteCalendar(ctx context.Context, path string) error {
+	name, dir, err := b.calendarDir(path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.readCalendar(name, dir); err != nil {
+		return err
+	}
+	return internal.ErrFromOS(os.RemoveAll(dir))
+}
+
+func (b *LocalBackend) UpdateCalendar(ctx context.Context, path string, update *CalendarUpdate) error {
+	name, dir, err := b.calendarDir(path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cal, err := b.readCalendar(name, dir)
+	if err != nil {
+		return err
+	}
+	update.apply(cal)
+	return writeCalendarMetadata(dir, cal)
+}
+
+func readCalendarObject(p, filename string) (*CalendarObject, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+	data, err := ical.NewDecoder(f).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("caldav: failed to decode calendar object %q: %v", p, err)
+	}
+
+	return &CalendarObject{
+		Path:          p,
+		ModTime:       fi.ModTime().UTC(),
+		ContentLength: fi.Size(),
+		ETag:          internal.FileETag(fi),
+		Data:          data,
+	}, nil
+}
+
+func (b *LocalBackend) GetCalendarObject(ctx context.Context, path string, req *CalendarCompRequest) (*CalendarObject, error) {
+	_, dir, file, err := b.objectFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return readCalendarObject(internal.CleanPath(path), filepath.Join(dir, file))
+}
+
+// listCalendarObjects returns all calendar objects in the calendar at p. The
+// caller must hold b.mu.
+func (b *LocalBackend) listCalendarObjects(p string) ([]CalendarObject, error) {
+	name, dir, err := b.calendarDir(p)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+
+	calPath := b.calendarPath(name)
+	var l []CalendarObject
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !internal.IsValidLocalName(entry.Name()) || filepath.Ext(entry.Name()) != ".ics" {
+			continue
+		}
+		co, err := readCalendarObject(calPath+entry.Name(), filepath.Join(dir, entry.Name()))
+		if _, ok := err.(*internal.HTTPError); ok {
+			return nil, err
+		} else if err != nil {
+			// Skip objects which can't be decoded, so that a single bad
+			// file doesn't make the whole calendar unavailable
+			continue
+		}
+		l = append(l, *co)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Path < l[j].Path
+	})
+	return l, nil
+}
+
+func (b *LocalBackend) ListCalendarObjects(ctx context.Context, path string, req *CalendarCompRequest) ([]CalendarObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.listCalendarObjects(path)
+}
+
+func (b *LocalBackend) QueryCalendarObjects(ctx context.Context, path string, query *CalendarQuery) ([]CalendarObject, error) {
+	cos, err := b.ListCalendarObjects(ctx, path, &query.CompRequest)
+	if err != nil {
+		return nil, err
+	}
+	return Filter(query, cos)
+}
+
+func (b *LocalBackend) PutCalendarObject(ctx context.Context, path string, calendar *ical.Calendar, opts *PutCalendarObjectOptions) (*CalendarObject, bool, error) {
+	calPath, dir, file, err := b.objectFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, uid, err := ValidateCalendarObject(calendar)
+	if err != nil {
+		return nil, false, NewPreconditionError(PreconditionValidCalendarObjectResource)
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(calendar); err != nil {
+		return nil, false, NewPreconditionError(PreconditionValidCalendarData)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cos, err := b.listCalendarObjects(calPath)
+	if httpErr, ok := err.(*internal.HTTPError); ok && httpErr.Code == http.StatusNotFound {
+		return nil, false, internal.HTTPErrorf(http.StatusConflict, "caldav: no calendar at %q", calPath)
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	// Objects which can't be decoded aren't listed, check the file itself
+	etag, err := internal.StatETag(filepath.Join(dir, file))
+	if err != nil {
+		return nil, false, err
+	}
+	created := etag == ""
+
+	p := calPath + file
+	for _, co := range cos {
+		if co.Path == p {
+			continue
+		}
+		if _, otherUID, err := ValidateCalendarObject(co.Data); err == nil && otherUID == uid {
+			return nil, false, NewUIDConflictError(co.Path)
+		}
+	}
+	if err := checkConditionalMatch(opts, etag); err != nil {
+		return nil, false, err
+	}
+
+	if err := internal.WriteFileAtomic(dir, file, buf.Bytes()); err != nil {
+		return nil, false, err
+	}
+	co, err := readCalendarObject(p, filepath.Join(dir, file))
+	return co, created, err
+}
+
+func (b *LocalBackend) DeleteCalendarObject(ctx context.Context, path string) error {
+	_, dir, file, err := b.objectFile(path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return internal.ErrFromOS(os.Remove(filepath.Join(dir, file)))
+}
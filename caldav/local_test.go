@@ -0,0 +1,151 @@
+package caldav
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	webdav "github.com/trvita/caldav-client-yandex"
+)
+
+func TestLocalBackend(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	b := NewLocalBackend(dir, "/user/", "/user/calendars/")
+
+	err := b.CreateCalendar(ctx, &Calendar{
+		Path:                  "/user/calendars/work",
+		Name:                  "Work",
+		Description:           "Meetings",
+		SupportedComponentSet: []string{"VEVENT", "VTODO"},
+	})
+	if err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/work/"}); httpErrorCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("CreateCalendar() on existing calendar = %v, expected 405", err)
+	}
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/b/"}); httpErrorCode(err) != http.StatusNotFound {
+		t.Errorf("CreateCalendar() outside home set = %v, expected 404", err)
+	}
+
+	// Metadata must survive a new backend instance
+	cals, err := NewLocalBackend(dir, "/user/", "/user/calendars/").ListCalendars(ctx)
+	if err != nil {
+		t.Fatalf("ListCalendars() = %v", err)
+	}
+	if len(cals) != 1 || cals[0].Path != "/user/calendars/work/" || cals[0].Name != "Work" || cals[0].Description != "Meetings" || len(cals[0].SupportedComponentSet) != 2 {
+		t.Errorf("ListCalendars() = %+v", cals)
+	}
+
+	name, color := "Office", "#0000FF"
+	if err := b.UpdateCalendar(ctx, "/user/calendars/work/", &CalendarUpdate{Name: &name, Color: &color}); err != nil {
+		t.Fatalf("UpdateCalendar() = %v", err)
+	}
+	cal, err := NewLocalBackend(dir, "/user/", "/user/calendars/").GetCalendar(ctx, "/user/calendars/work/")
+	if err != nil {
+		t.Fatalf("GetCalendar() = %v", err)
+	}
+	if cal.Name != name || cal.Color != color || cal.Description != "Meetings" {
+		t.Errorf("GetCalendar() after update = %+v", cal)
+	}
+
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	co, created, err := b.PutCalendarObject(ctx, "/user/calendars/work/1.ics", newTestCalendar("1", "Event #1", start), &PutCalendarObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch("*"),
+	})
+	if err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+	if !created {
+		t.Errorf("PutCalendarObject() of new object reported an update")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "work", "1.ics")); err != nil {
+		t.Errorf("calendar object not stored on disk: %v", err)
+	}
+	_, _, err = b.PutCalendarObject(ctx, "/user/calendars/work/1.ics", newTestCalendar("1", "Event #1", start), &PutCalendarObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch("*"),
+	})
+	if httpErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("PutCalendarObject() with If-None-Match on existing object = %v, expected 412", err)
+	}
+	_, _, err = b.PutCalendarObject(ctx, "/user/calendars/work/2.ics", newTestCalendar("1", "Duplicate", start), nil)
+	if httpErrorCode(err) != http.StatusConflict {
+		t.Errorf("PutCalendarObject() with duplicate UID = %v, expected 409", err)
+	}
+	_, _, err = b.PutCalendarObject(ctx, "/user/calendars/home/1.ics", newTestCalendar("2", "No calendar", start), nil)
+	if httpErrorCode(err) != http.StatusConflict {
+		t.Errorf("PutCalendarObject() in missing calendar = %v, expected 409", err)
+	}
+
+	got, err := b.GetCalendarObject(ctx, co.Path, &CalendarCompRequest{})
+	if err != nil {
+		t.Fatalf("GetCalendarObject() = %v", err)
+	}
+	if got.ETag != co.ETag {
+		t.Errorf("GetCalendarObject() ETag = %q, expected %q", got.ETag, co.ETag)
+	}
+	if summary, _ := got.Data.Events()[0].Props.Text("SUMMARY"); summary != "Event #1" {
+		t.Errorf("GetCalendarObject() summary = %q, expected %q", summary, "Event #1")
+	}
+
+	cos, err := b.QueryCalendarObjects(ctx, "/user/calendars/work/", &CalendarQuery{
+		CompFilter: CompFilter{
+			Name:  "VCALENDAR",
+			Comps: []CompFilter{{Name: "VEVENT", Start: start.Add(24 * time.Hour)}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryCalendarObjects() = %v", err)
+	}
+	if len(cos) != 0 {
+		t.Errorf("QueryCalendarObjects() = %+v, expected no objects", cos)
+	}
+
+	// A file which can't be decoded must not break listings
+	if err := os.WriteFile(filepath.Join(dir, "work", "broken.ics"), []byte("BEGIN:VCALENDAR\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cos, err = b.ListCalendarObjects(ctx, "/user/calendars/work/", &CalendarCompRequest{})
+	if err != nil {
+		t.Fatalf("ListCalendarObjects() with a broken file = %v", err)
+	}
+	if len(cos) != 1 || cos[0].Path != co.Path {
+		t.Errorf("ListCalendarObjects() with a broken file = %+v, expected only %v", cos, co.Path)
+	}
+
+	// ... but it still exists when overwritten
+	broken := "/user/calendars/work/broken.ics"
+	_, _, err = b.PutCalendarObject(ctx, broken, newTestCalendar("3", "Fixed", start), &PutCalendarObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch("*"),
+	})
+	if httpErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("PutCalendarObject() with If-None-Match on a broken file = %v, expected 412", err)
+	}
+	_, created, err = b.PutCalendarObject(ctx, broken, newTestCalendar("3", "Fixed", start), &PutCalendarObjectOptions{
+		IfMatch: webdav.ConditionalMatch("*"),
+	})
+	if err != nil || created {
+		t.Errorf("PutCalendarObject() with If-Match on a broken file = %v, created %v", err, created)
+	}
+
+	if err := b.DeleteCalendarObject(ctx, co.Path); err != nil {
+		t.Fatalf("DeleteCalendarObject() = %v", err)
+	}
+	if err := b.DeleteCalendarObject(ctx, co.Path); httpErrorCode(err) != http.StatusNotFound {
+		t.Errorf("DeleteCalendarObject() on deleted object = %v, expected 404", err)
+	}
+
+	if err := b.DeleteCalendar(ctx, "/user/calendars/work/"); err != nil {
+		t.Fatalf("DeleteCalendar() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "work")); !os.IsNotExist(err) {
+		t.Errorf("calendar directory not removed: %v", err)
+	}
+	if err := b.DeleteCalendar(ctx, "/user/calendars/work/"); httpErrorCode(err) != http.StatusNotFound {
+		t.Errorf("DeleteCalendar() on deleted calendar = %v, expected 404", err)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/trvita/caldav-client-yandex/internal"
 	"github.com/trvita/go-ical"
 )
 
 // Filter returns the filtered list of calendar objects matching the provided query.
-// A nil query will return the full list of calendar objects.
+// A nil query will return the full list of calendar objects. Calendar data is
+// left untouched: query.CompRequest is applied by the Handler.
 func Filter(query *CalendarQuery, cos []CalendarObject) ([]CalendarObject, error) {
 	if query == nil {
 		// FIXME: should we always return a copy of the provided slice?
@@ -24,8 +26,6 @@ func Filter(query *CalendarQuery, cos []CalendarObject) ([]CalendarObject, error
 		if !ok {
 			continue
 		}
-
-		// TODO properties are not currently filtered even if requested
 		out = append(out, co)
 	}
 	return out, nil
@@ -36,17 +36,16 @@ func Match(query CompFilter, co *CalendarObject) (matched bool, err error) {
 	if co.Data == nil || co.Data.Component == nil {
 		panic("request to process empty calendar object")
 	}
-	return match(query, co.Data.Component)
+	return match(query, co.Data.Component, nil)
 }
 
-func match(filter CompFilter, comp *ical.Component) (bool, error) {
+func match(filter CompFilter, comp, parent *ical.Component) (bool, error) {
 	if comp.Name != filter.Name {
 		return filter.IsNotDefined, nil
 	}
 
-	var zeroDate time.Time
-	if filter.Start != zeroDate {
-		match, err := matchCompTimeRange(filter.Start, filter.End, comp)
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		match, err := matchCompTimeRange(filter.Start, filter.End, comp, parent)
 		if err != nil {
 			return false, err
 		}
@@ -79,7 +78,7 @@ func matchCompFilter(filter CompFilter, comp *ical.Component) (bool, error) {
 	var matches []*ical.Component
 
 	for _, child := range comp.Children {
-		match, err := match(filter, child)
+		match, err := match(filter, child, comp)
 		if err != nil {
 			return false, err
 		} else if match {
@@ -93,81 +92,362 @@ func matchCompFilter(filter CompFilter, comp *ical.Component) (bool, error) {
 }
 
 func matchPropFilter(filter PropFilter, comp *ical.Component) (bool, error) {
-	// TODO: this only matches first field, there can be multiple
-	field := comp.Props.Get(filter.Name)
-	if field == nil {
+	fields := comp.Props.Values(filter.Name)
+	if len(fields) == 0 {
 		return filter.IsNotDefined, nil
+	} else if filter.IsNotDefined {
+		return false, nil
 	}
 
-	for _, paramFilter := range filter.ParamFilter {
-		if !matchParamFilter(paramFilter, field) {
-			return false, nil
+	// Properties such as ATTENDEE can appear multiple times, the filter
+	// matches if any of them does
+	for i := range fields {
+		match, err := matchProp(filter, &fields[i])
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
 		}
 	}
+	return false, nil
+}
 
-	var zeroDate time.Time
-	if filter.Start != zeroDate {
-		match, err := matchPropTimeRange(filter.Start, filter.End, field)
-		if err != nil {
+func matchProp(filter PropFilter, field *ical.Prop) (bool, error) {
+	for _, paramFilter := range filter.ParamFilter {
+		match, err := matchParamFilter(paramFilter, field)
+		if err != nil || !match {
 			return false, err
 		}
-		if !match {
-			return false, nil
-		}
+	}
+
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		return matchPropTimeRange(filter.Start, filter.End, field)
 	} else if filter.TextMatch != nil {
-		if !matchTextMatch(*filter.TextMatch, field.Value) {
-			return false, nil
-		}
-		return true, nil
+		return matchTextMatch(*filter.TextMatch, field.Value)
 	}
 	// empty prop-filter, property exists
 	return true, nil
 }
 
-func matchCompTimeRange(start, end time.Time, comp *ical.Component) (bool, error) {
+// maxTime stands in for an unbounded end of a time range.
+var maxTime = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+func matchCompTimeRange(start, end time.Time, comp, parent *ical.Component) (bool, error) {
 	// See https://datatracker.ietf.org/doc/html/rfc4791#section-9.9
 
-	// evaluate recurring components
-	rset, err := comp.RecurrenceSet(start.Location())
+	limit := end
+	if end.IsZero() {
+		end = maxTime
+	}
+	if comp.Name == ical.CompAlarm {
+		return matchAlarmTimeRange(start, end, comp, parent)
+	}
+
+	// An instance can only overlap the range if it starts before its end,
+	// and ends after its start
+	duration, err := instanceSpan(comp)
 	if err != nil {
 		return false, err
 	}
-	if rset != nil {
-		// TODO we can only set inclusive to true or false, but really the
-		// start time is inclusive while the end time is not :/
-		return len(rset.Between(start, end, true)) > 0, nil
-	}
+	return matchInstances(comp, start.Add(-duration), limit, func(instance *ical.Component) (bool, error) {
+		return matchInstanceTimeRange(start, end, instance)
+	})
+}
 
-	// TODO handle more than just events
-	if comp.Name != ical.CompEvent {
-		return false, nil
+// instanceSpan returns the duration of comp, with an extra day of slack for
+// DATE values since all-day instances can last a day without an end.
+func instanceSpan(comp *ical.Component) (time.Duration, error) {
+	if comp.Props.Get(ical.PropDateTimeStart) == nil {
+		return 0, nil
+	}
+	duration, err := componentDuration(comp)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		duration = 0
 	}
-	event := ical.Event{comp}
+	if comp.Props.Get(ical.PropDateTimeStart).ValueType() == ical.ValueDate {
+		duration += 24 * time.Hour
+	}
+	return duration, nil
+}
 
-	eventStart, err := event.DateTimeStart(start.Location())
+// matchInstances calls f with each instance of a possibly recurring
+// component starting between from and limit, until f returns true. A zero
+// limit leaves the range open.
+func matchInstances(comp *ical.Component, from, limit time.Time, f func(instance *ical.Component) (bool, error)) (bool, error) {
+	rset, err := comp.RecurrenceSet(time.UTC)
 	if err != nil {
 		return false, err
 	}
-	eventEnd, err := event.DateTimeEnd(end.Location())
+	if rset == nil {
+		return f(comp)
+	}
+
+	match := func(t time.Time) (bool, error) {
+		instance, err := shiftComponent(comp, t)
+		if err != nil {
+			return false, err
+		}
+		return f(instance)
+	}
+
+	if !limit.IsZero() {
+		for _, t := range rset.Between(from, limit, true) {
+			if ok, err := match(t); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	// The recurrence set can be infinite, iterate until the first match
+	next := rset.Iterator()
+	for t, ok := next(); ok && t.Before(maxTime); t, ok = next() {
+		if t.Before(from) {
+			continue
+		}
+		if ok, err := match(t); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// shiftComponent returns a copy of comp moved to start at t, along with its
+// DTEND and DUE properties.
+func shiftComponent(comp *ical.Component, t time.Time) (*ical.Component, error) {
+	dtstart, err := comp.Props.DateTime(ical.PropDateTimeStart, time.UTC)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
+	delta := t.Sub(dtstart)
 
-	// Event starts in time range
-	if eventStart.After(start) && (end.IsZero() || eventStart.Before(end)) {
-		return true, nil
+	out := ical.NewComponent(comp.Name)
+	out.Children = comp.Children
+	for name, props := range comp.Props {
+		out.Props[name] = props
 	}
-	// Event ends in time range
-	if eventEnd.After(start) && (end.IsZero() || eventEnd.Before(end)) {
-		return true, nil
+	for _, name := range []string{ical.PropDateTimeStart, ical.PropDateTimeEnd, ical.PropDue} {
+		prop := comp.Props.Get(name)
+		if prop == nil {
+			continue
+		}
+		v, err := prop.DateTime(time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		shifted := ical.NewProp(name)
+		if prop.ValueType() == ical.ValueDate {
+			shifted.SetDate(v.Add(delta))
+		} else {
+			shifted.SetDateTime(v.Add(delta).UTC())
+		}
+		out.Props.Set(shifted)
 	}
-	// Event covers entire time range plus some
-	if eventStart.Before(start) && (!end.IsZero() && eventEnd.After(end)) {
-		return true, nil
+	return out, nil
+}
+
+// matchInstanceTimeRange reports whether a single instance of a component
+// overlaps the time range.
+func matchInstanceTimeRange(start, end time.Time, comp *ical.Component) (bool, error) {
+	loc := start.Location()
+	switch comp.Name {
+	case ical.CompEvent:
+		event := ical.Event{comp}
+		eventStart, err := event.DateTimeStart(loc)
+		if err != nil {
+			return false, err
+		}
+		eventEnd, err := event.DateTimeEnd(loc)
+		if err != nil {
+			return false, err
+		}
+		if eventEnd.After(eventStart) {
+			return start.Before(eventEnd) && end.After(eventStart), nil
+		}
+		return !start.After(eventStart) && end.After(eventStart), nil
+	case ical.CompToDo:
+		return matchToDoTimeRange(start, end, comp)
+	case ical.CompJournal:
+		prop := comp.Props.Get(ical.PropDateTimeStart)
+		if prop == nil {
+			return false, nil
+		}
+		journalStart, err := prop.DateTime(loc)
+		if err != nil {
+			return false, err
+		}
+		// A journal entry with a DATE value lasts the whole day
+		if prop.ValueType() == ical.ValueDate {
+			return start.Before(journalStart.AddDate(0, 0, 1)) && end.After(journalStart), nil
+		}
+		return !start.After(journalStart) && end.After(journalStart), nil
+	case ical.CompFreeBusy:
+		return matchFreeBusyTimeRange(start, end, comp)
+	}
+	return false, nil
+}
+
+func matchToDoTimeRange(start, end time.Time, comp *ical.Component) (bool, error) {
+	loc := start.Location()
+	props := make(map[string]time.Time)
+	for _, name := range []string{ical.PropDateTimeStart, ical.PropDue, ical.PropCompleted, ical.PropCreated} {
+		if comp.Props.Get(name) == nil {
+			continue
+		}
+		t, err := comp.Props.DateTime(name, loc)
+		if err != nil {
+			return false, err
+		}
+		props[name] = t
+	}
+	dtstart, hasStart := props[ical.PropDateTimeStart]
+	due, hasDue := props[ical.PropDue]
+	completed, hasCompleted := props[ical.PropCompleted]
+	created, hasCreated := props[ical.PropCreated]
+
+	if hasStart {
+		if durProp := comp.Props.Get(ical.PropDuration); durProp != nil {
+			dur, err := durProp.Duration()
+			if err != nil {
+				return false, err
+			}
+			dtend := dtstart.Add(dur)
+			return !start.After(dtend) && (end.After(dtstart) || !end.Before(dtend)), nil
+		}
+		if hasDue {
+			return (start.Before(due) || !start.After(dtstart)) && (end.After(dtstart) || !end.Before(due)), nil
+		}
+		return !start.After(dtstart) && end.After(dtstart), nil
+	}
+	if hasDue {
+		return start.Before(due) && !end.Before(due), nil
+	}
+	switch {
+	case hasCompleted && hasCreated:
+		return (!start.After(created) || !start.After(completed)) && (!end.Before(created) || !end.Before(completed)), nil
+	case hasCompleted:
+		return !start.After(completed) && !end.Before(completed), nil
+	case hasCreated:
+		return end.After(created), nil
+	}
+	return true, nil
+}
+
+func matchFreeBusyTimeRange(start, end time.Time, comp *ical.Component) (bool, error) {
+	if comp.Props.Get(ical.PropDateTimeStart) != nil && comp.Props.Get(ical.PropDateTimeEnd) != nil {
+		fbStart, err := comp.Props.DateTime(ical.PropDateTimeStart, time.UTC)
+		if err != nil {
+			return false, err
+		}
+		fbEnd, err := comp.Props.DateTime(ical.PropDateTimeEnd, time.UTC)
+		if err != nil {
+			return false, err
+		}
+		return !start.After(fbEnd) && end.After(fbStart), nil
+	}
+
+	for _, prop := range comp.Props.Values(ical.PropFreeBusy) {
+		for _, s := range strings.Split(prop.Value, ",") {
+			p, err := parseFreeBusyPeriod(s)
+			if err != nil {
+				return false, err
+			}
+			if start.Before(p.End) && end.After(p.Start) {
+				return true, nil
+			}
+		}
 	}
 	return false, nil
 }
 
+// matchAlarmTimeRange reports whether an alarm triggers within the time
+// range, for any instance of its parent component.
+func matchAlarmTimeRange(start, end time.Time, alarm, parent *ical.Component) (bool, error) {
+	trigger := alarm.Props.Get(ical.PropTrigger)
+	if trigger == nil || parent == nil {
+		return false, nil
+	}
+
+	var repeat int
+	var interval time.Duration
+	if prop := alarm.Props.Get(ical.PropRepeat); prop != nil {
+		n, err := prop.Int()
+		if err != nil {
+			return false, err
+		}
+		if durProp := alarm.Props.Get(ical.PropDuration); durProp != nil {
+			if interval, err = durProp.Duration(); err != nil {
+				return false, err
+			}
+			repeat = n
+		}
+	}
+	matchTriggers := func(first time.Time) bool {
+		for i := 0; i <= repeat; i++ {
+			t := first.Add(time.Duration(i) * interval)
+			if !start.After(t) && end.After(t) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if trigger.ValueType() == ical.ValueDateTime {
+		t, err := trigger.DateTime(time.UTC)
+		if err != nil {
+			return false, err
+		}
+		return matchTriggers(t), nil
+	}
+
+	offset, err := trigger.Duration()
+	if err != nil {
+		return false, err
+	}
+	related := ical.PropDateTimeStart
+	if strings.EqualFold(trigger.Params.Get(ical.ParamRelated), "END") {
+		related = ical.PropDateTimeEnd
+		if parent.Name == ical.CompToDo {
+			related = ical.PropDue
+		}
+	}
+
+	// Triggers fire between the start of the instance plus the offset, and
+	// its end plus the offset and repetitions
+	span := time.Duration(repeat) * interval
+	if related != ical.PropDateTimeStart {
+		duration, err := instanceSpan(parent)
+		if err != nil {
+			return false, err
+		}
+		span += duration
+	}
+	limit := end.Add(-offset)
+	if end.Equal(maxTime) {
+		limit = time.Time{}
+	}
+	return matchInstances(parent, start.Add(-offset-span), limit, func(instance *ical.Component) (bool, error) {
+		var base time.Time
+		var err error
+		if related == ical.PropDateTimeEnd {
+			event := ical.Event{instance}
+			base, err = event.DateTimeEnd(time.UTC)
+		} else if instance.Props.Get(related) != nil {
+			base, err = instance.Props.DateTime(related, time.UTC)
+		} else {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return matchTriggers(base.Add(offset)), nil
+	})
+}
+
 func matchPropTimeRange(start, end time.Time, field *ical.Prop) (bool, error) {
 	// See https://datatracker.ietf.org/doc/html/rfc4791#section-9.9
 
@@ -181,25 +461,43 @@ func matchPropTimeRange(start, end time.Time, field *ical.Prop) (bool, error) {
 	return false, nil
 }
 
-func matchParamFilter(filter ParamFilter, field *ical.Prop) bool {
-	// TODO there can be multiple values
-	value := field.Params.Get(filter.Name)
-	if value == "" {
-		return filter.IsNotDefined
+func matchParamFilter(filter ParamFilter, field *ical.Prop) (bool, error) {
+	// Comma-separated values such as MEMBER="a","b" are decoded separately
+	values := field.Params.Values(filter.Name)
+	if len(values) == 0 {
+		return filter.IsNotDefined, nil
 	} else if filter.IsNotDefined {
-		return false
+		return false, nil
 	}
-	if filter.TextMatch != nil {
-		return matchTextMatch(*filter.TextMatch, value)
+	if filter.TextMatch == nil {
+		return true, nil
+	}
+	for _, value := range values {
+		match, err := matchTextMatch(*filter.TextMatch, value)
+		if err != nil || match {
+			return match, err
+		}
 	}
-	return true
+	return false, nil
 }
 
-func matchTextMatch(txt TextMatch, value string) bool {
-	// TODO: handle text-match collation attribute
-	match := strings.Contains(value, txt.Text)
+func matchTextMatch(txt TextMatch, value string) (bool, error) {
+	collation := txt.Collation
+	if collation == "" {
+		collation = CollationASCIICasemap
+	}
+	text, err := internal.FoldCollation(string(collation), txt.Text)
+	if err != nil {
+		return false, err
+	}
+	value, err = internal.FoldCollation(string(collation), value)
+	if err != nil {
+		return false, err
+	}
+
+	match := strings.Contains(value, text)
 	if txt.NegateCondition {
 		match = !match
 	}
-	return match
+	return match, nil
 }
@@ -1,6 +1,7 @@
 package caldav
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -160,6 +161,75 @@ ACTION:AUDIO
 TRIGGER;RELATED=START:-PT10M
 END:VALARM
 END:VTODO
+END:VCALENDAR`)
+
+	event4 := newCO(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+ATTENDEE;PARTSTAT=ACCEPTED:mailto:cyrus@example.com
+ATTENDEE;MEMBER="mailto:dev@example.com","mailto:ops@example.com":mailto:lisa@example.com
+CATEGORIES:Work
+CATEGORIES:Travel,Offsite
+DTSTAMP:20060206T001220Z
+DTSTART:20060105T100000Z
+DURATION:PT1H
+SUMMARY:Event #4
+UID:DC6C50A017428C5216A2F1CE@example.com
+END:VEVENT
+END:VCALENDAR`)
+
+	todo2 := newCO(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTODO
+DTSTAMP:20060205T235600Z
+DTSTART:20060107T130000Z
+DUE:20060108T130000Z
+STATUS:NEEDS-ACTION
+SUMMARY:Task #2
+UID:E10BA47467C5C69BB74E8720@example.com
+BEGIN:VALARM
+ACTION:AUDIO
+TRIGGER;RELATED=START:-PT10M
+REPEAT:2
+DURATION:PT5M
+END:VALARM
+END:VTODO
+END:VCALENDAR`)
+
+	todo3 := newCO(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTODO
+DTSTAMP:20060205T235600Z
+CREATED:20060101T100000Z
+COMPLETED:20060105T100000Z
+STATUS:COMPLETED
+SUMMARY:Task #3
+UID:E10BA47467C5C69BB74E8721@example.com
+END:VTODO
+END:VCALENDAR`)
+
+	journal1 := newCO(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VJOURNAL
+DTSTAMP:20060206T001121Z
+DTSTART;VALUE=DATE:20060102
+SUMMARY:Journal #1
+UID:0F8BA47467C5C69BB74E8722@example.com
+END:VJOURNAL
+END:VCALENDAR`)
+
+	freebusy1 := newCO(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VFREEBUSY
+DTSTAMP:20060206T001121Z
+UID:0F8BA47467C5C69BB74E8723@example.com
+FREEBUSY:20060104T150000Z/PT1H,20060104T190000Z/20060104T200000Z
+END:VFREEBUSY
 END:VCALENDAR`)
 
 	for _, tc := range []struct {
@@ -226,6 +296,22 @@ END:VCALENDAR`)
 			addrs: []CalendarObject{event1, event2, event3, todo1},
 			want:  []CalendarObject{event2, event3},
 		},
+		{
+			name: "events in open time range (no start date)",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{
+						CompFilter{
+							Name: "VEVENT",
+							End:  toDate(t, "20060103T000000Z"),
+						},
+					},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, todo1},
+			want:  []CalendarObject{event1, event2},
+		},
 		{
 			// https://datatracker.ietf.org/doc/html/rfc4791#section-7.8.6
 			name: "events by UID",
@@ -288,6 +374,283 @@ END:VCALENDAR`)
 			addrs: []CalendarObject{event1, event2, event3, todo1},
 			want:  []CalendarObject{event2},
 		},
+		{
+			name: "todos with only a due date in time range",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name:  "VTODO",
+						Start: toDate(t, "20060103T000000Z"),
+						End:   toDate(t, "20060104T000000Z"),
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, todo1, todo2, todo3},
+			// todo3 was open from its creation until its completion
+			want: []CalendarObject{todo1, todo3},
+		},
+		{
+			name: "todos with a start and due date in time range",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name:  "VTODO",
+						Start: toDate(t, "20060108T000000Z"),
+						End:   toDate(t, "20060109T000000Z"),
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, todo1, todo2, todo3},
+			want:  []CalendarObject{todo2},
+		},
+		{
+			name: "completed todos in time range",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name:  "VTODO",
+						Start: toDate(t, "20060105T000000Z"),
+						End:   toDate(t, "20060106T000000Z"),
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, todo1, todo2, todo3},
+			want:  []CalendarObject{todo3},
+		},
+		{
+			name: "all-day journals in time range",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name:  "VJOURNAL",
+						Start: toDate(t, "20060102T120000Z"),
+						End:   toDate(t, "20060103T000000Z"),
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, todo1, journal1},
+			want:  []CalendarObject{journal1},
+		},
+		{
+			// https://datatracker.ietf.org/doc/html/rfc4791#section-7.8.5
+			name: "todos with repeated alarms in time range",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VTODO",
+						Comps: []CompFilter{{
+							Name:  "VALARM",
+							Start: toDate(t, "20060107T125500Z"),
+							End:   toDate(t, "20060107T125600Z"),
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{todo1, todo2, todo3},
+			want:  []CalendarObject{todo2},
+		},
+		{
+			name: "free-busy periods in time range",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name:  "VFREEBUSY",
+						Start: toDate(t, "20060104T193000Z"),
+						End:   toDate(t, "20060104T210000Z"),
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, todo1, freebusy1},
+			want:  []CalendarObject{freebusy1},
+		},
+		{
+			name: "events by any attendee",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name:      "ATTENDEE",
+							TextMatch: &TextMatch{Text: "mailto:lisa@example.com"},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, event4},
+			want:  []CalendarObject{event3, event4},
+		},
+		{
+			name: "events by attendee participation status",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name: "ATTENDEE",
+							ParamFilter: []ParamFilter{{
+								Name:      "PARTSTAT",
+								TextMatch: &TextMatch{Text: "NEEDS-ACTION"},
+							}},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, event4},
+			want:  []CalendarObject{event3},
+		},
+		{
+			name: "events by attendee without participation status",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name: "ATTENDEE",
+							ParamFilter: []ParamFilter{{
+								Name:         "PARTSTAT",
+								IsNotDefined: true,
+							}},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, event4},
+			want:  []CalendarObject{event4},
+		},
+		{
+			name: "events by group membership",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name: "ATTENDEE",
+							ParamFilter: []ParamFilter{{
+								Name:      "MEMBER",
+								TextMatch: &TextMatch{Text: "mailto:ops@example.com"},
+							}},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, event4},
+			want:  []CalendarObject{event4},
+		},
+		{
+			name: "events by category",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name:      "CATEGORIES",
+							TextMatch: &TextMatch{Text: "Travel"},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, event4},
+			want:  []CalendarObject{event4},
+		},
+		{
+			name: "events without categories",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name:         "CATEGORIES",
+							IsNotDefined: true,
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event3, event4},
+			want:  []CalendarObject{event1, event3},
+		},
+		{
+			name: "events by summary with the default collation",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name:      "SUMMARY",
+							TextMatch: &TextMatch{Text: "event #3"},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3},
+			want:  []CalendarObject{event3},
+		},
+		{
+			name: "events by summary with the octet collation",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name:      "SUMMARY",
+							TextMatch: &TextMatch{Text: "event #3", Collation: CollationOctet},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3},
+			want:  nil,
+		},
+		{
+			name: "events by attendee status with the unicode collation",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name: "ATTENDEE",
+							ParamFilter: []ParamFilter{{
+								Name:      "PARTSTAT",
+								TextMatch: &TextMatch{Text: "needs-action", Collation: CollationUnicodeCasemap},
+							}},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, event4},
+			want:  []CalendarObject{event3},
+		},
+		{
+			name: "events by summary with an unsupported collation",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{{
+						Name: "VEVENT",
+						Props: []PropFilter{{
+							Name:      "SUMMARY",
+							TextMatch: &TextMatch{Text: "event", Collation: "i;basic"},
+						}},
+					}},
+				},
+			},
+			addrs: []CalendarObject{event1},
+			err:   fmt.Errorf("webdav: unsupported collation \"i;basic\""),
+		},
 		// TODO add more examples
 	} {
 		t.Run(tc.name, func(t *testing.T) {
@@ -309,3 +672,104 @@ END:VCALENDAR`)
 		})
 	}
 }
+
+func TestMatchRecurringTimeRange(t *testing.T) {
+	newComp := func(name, props string) *ical.Component {
+		cal, err := ical.NewDecoder(strings.NewReader("BEGIN:VCALENDAR\n" +
+			"VERSION:2.0\n" +
+			"PRODID:-//Example Corp.//CalDAV Client//EN\n" +
+			"BEGIN:" + name + "\n" +
+			"UID:test@example.com\n" +
+			"DTSTAMP:20060206T001121Z\n" +
+			props +
+			"END:" + name + "\n" +
+			"END:VCALENDAR\n")).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cal.Children[0]
+	}
+
+	minutely := newComp("VEVENT", "DTSTART:20230101T000000Z\nDURATION:PT30S\nRRULE:FREQ=MINUTELY\n")
+	weekly := newComp("VEVENT", "DTSTART:20240101T090000Z\nDTEND:20240101T110000Z\nRRULE:FREQ=WEEKLY\n")
+	allDay := newComp("VEVENT", "DTSTART;VALUE=DATE:20240101\nRRULE:FREQ=WEEKLY\n")
+	todo := newComp("VTODO", "DTSTART:20240101T090000Z\nDUE:20240101T170000Z\nRRULE:FREQ=WEEKLY\n")
+
+	for _, tc := range []struct {
+		name       string
+		comp       *ical.Component
+		start, end string
+		want       bool
+	}{
+		{"minutely a year later", minutely, "20240101T000010Z", "20240101T000020Z", true},
+		{"minutely between instances", minutely, "20240101T000040Z", "20240101T000050Z", false},
+		{"minutely open end", minutely, "20240101T000040Z", "", true},
+		{"instance overlapping the start", weekly, "20240108T100000Z", "20240108T120000Z", true},
+		{"between instances", weekly, "20240108T110000Z", "20240115T090000Z", false},
+		{"all-day instance", allDay, "20240108T120000Z", "20240108T130000Z", true},
+		{"all-day between instances", allDay, "20240109T120000Z", "20240109T130000Z", false},
+		{"to-do before due", todo, "20240108T160000Z", "20240108T163000Z", true},
+		{"to-do after due", todo, "20240108T170000Z", "20240108T180000Z", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var end time.Time
+			if tc.end != "" {
+				end = toDate(t, tc.end)
+			}
+			got, err := matchCompTimeRange(toDate(t, tc.start), end, tc.comp, nil)
+			if err != nil {
+				t.Fatalf("matchCompTimeRange() = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("matchCompTimeRange() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchRecurringAlarmTimeRange(t *testing.T) {
+	cal, err := ical.NewDecoder(strings.NewReader(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:test@example.com
+DTSTAMP:20060206T001121Z
+DTSTART:20240101T090000Z
+DTEND:20240101T100000Z
+RRULE:FREQ=WEEKLY
+BEGIN:VALARM
+ACTION:AUDIO
+TRIGGER;RELATED=END:PT10M
+REPEAT:2
+DURATION:PT5M
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := cal.Children[0]
+	alarm := event.Children[0]
+
+	for _, tc := range []struct {
+		start, end string
+		want       bool
+	}{
+		// Last repetition of the second instance's alarm
+		{"20240108T101900Z", "20240108T102100Z", true},
+		{"20240108T102100Z", "20240108T110000Z", false},
+		{"20240108T102100Z", "", true},
+	} {
+		var end time.Time
+		if tc.end != "" {
+			end = toDate(t, tc.end)
+		}
+		got, err := matchCompTimeRange(toDate(t, tc.start), end, alarm, event)
+		if err != nil {
+			t.Fatalf("matchCompTimeRange(%v, %v) = %v", tc.start, tc.end, err)
+		} else if got != tc.want {
+			t.Errorf("matchCompTimeRange(%v, %v) = %v, want %v", tc.start, tc.end, got, tc.want)
+		}
+	}
+}
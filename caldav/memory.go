@@ -0,0 +1,383 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trvita/caldav-client-yandex/internal"
+	"github.com/trvita/go-ical"
+)
+
+// MemoryBackend is a Backend keeping calendars in memory. It's safe for
+// concurrent use, and is mostly useful for tests and local development.
+type MemoryBackend struct {
+	principalPath string
+	homeSetPath   string
+
+	mu        sync.RWMutex
+	calendars map[string]*memoryCalendar
+}
+
+type memoryCalendar struct {
+	cal     Calendar
+	objects map[string]*memoryObject
+
+	// revision is incremented on each change and used as the sync token
+	revision   int64
+	tombstones map[string]int64
+}
+
+type memoryObject struct {
+	uid      string
+	data     []byte
+	modTime  time.Time
+	etag     string
+	revision int64
+}
+
+var (
+	_ Backend               = (*MemoryBackend)(nil)
+	_ SyncBackend           = (*MemoryBackend)(nil)
+	_ CalendarUpdateBackend = (*MemoryBackend)(nil)
+)
+
+// NewMemoryBackend creates a new empty MemoryBackend serving calendars for
+// the principal at principalPath, below homeSetPath.
+func NewMemoryBackend(principalPath, homeSetPath string) *MemoryBackend {
+	return &MemoryBackend{
+		principalPath: principalPath,
+		homeSetPath:   homeSetPath,
+		calendars:     make(map[string]*memoryCalendar),
+	}
+}
+
+func (b *MemoryBackend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	return b.principalPath, nil
+}
+
+func (b *MemoryBackend) CalendarHomeSetPath(ctx context.Context) (string, error) {
+	return b.homeSetPath, nil
+}
+
+func (b *MemoryBackend) CreateCalendar(ctx context.Context, calendar *Calendar) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := internal.CleanPath(calendar.Path)
+	if _, ok := b.calendars[p]; ok {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "caldav: calendar %q already exists", calendar.Path)
+	}
+	b.calendars[p] = &memoryCalendar{
+		cal:        *calendar,
+		objects:    make(map[string]*memoryObject),
+		tombstones: make(map[string]int64),
+	}
+	return nil
+}
+
+func (b *MemoryBackend) UpdateCalendar(ctx context.Context, path string, update *CalendarUpdate) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	mc, err := b.calendar(path)
+	if err != nil {
+		return err
+	}
+	update.apply(&mc.cal)
+	return nil
+}
+
+func (b *MemoryBackend) DeleteCalendar(ctx context.Context, path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.calendar(path); err != nil {
+		return err
+	}
+	delete(b.calendars, internal.CleanPath(path))
+	return nil
+}
+
+func (b *MemoryBackend) ListCalendars(ctx context.Context) ([]Calendar, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	l := make([]Calendar, 0, len(b.calendars))
+	for _, mc := range b.calendars {
+		l = append(l, mc.cal)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Path < l[j].Path
+	})
+	return l, nil
+}
+
+func (b *MemoryBackend) GetCalendar(ctx context.Context, path string) (*Calendar, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	mc, err := b.calendar(path)
+	if err != nil {
+		return nil, err
+	}
+	cal := mc.cal
+	return &cal, nil
+}
+
+// calendar returns the calendar at the provided path. The caller must hold
+// b.mu.
+func (b *MemoryBackend) calendar(p string) (*memoryCalendar, error) {
+	mc, ok := b.calendars[internal.CleanPath(p)]
+	if !ok {
+		return nil, internal.HTTPErrorf(http.StatusNotFound, "caldav: calendar %q not found", p)
+	}
+	return mc, nil
+}
+
+func (b *MemoryBackend) calendarObject(p string) (*memoryCalendar, *memoryObject, error) {
+	p = internal.CleanPath(p)
+	mc, ok := b.calendars[path.Dir(p)]
+	if !ok {
+		return nil, nil, internal.HTTPErrorf(http.StatusNotFound, "caldav: calendar object %q not found", p)
+	}
+	mo, ok := mc.objects[p]
+	if !ok {
+		return mc, nil, internal.HTTPErrorf(http.StatusNotFound, "caldav: calendar object %q not found", p)
+	}
+	return mc, mo, nil
+}
+
+func (mo *memoryObject) calendarObject(p string) (*CalendarObject, error) {
+	data, err := ical.NewDecoder(bytes.NewReader(mo.data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+	return &CalendarObject{
+		Path:          p,
+		ModTime:       mo.modTime,
+		ContentLength: int64(len(mo.data)),
+		ETag:          mo.etag,
+		Data:          data,
+	}, nil
+}
+
+func (mc *memoryCalendar) calendarObjects() ([]CalendarObject, error) {
+	paths := make([]string, 0, len(mc.objects))
+	for p := range mc.objects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	l := make([]CalendarObject, 0, len(paths))
+	for _, p := range paths {
+		co, err := mc.objects[p].calendarObject(p)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, *co)
+	}
+	return l, nil
+}
+
+func (b *MemoryBackend) GetCalendarObject(ctx context.Context, path string, req *CalendarCompRequest) (*CalendarObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, mo, err := b.calendarObject(path)
+	if err != nil {
+		return nil, err
+	}
+	return mo.calendarObject(internal.CleanPath(path))
+}
+
+func (b *MemoryBackend) ListCalendarObjects(ctx context.Context, path string, req *CalendarCompRequest) ([]CalendarObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	mc, err := b.calendar(path)
+	if err != nil {
+		return nil, err
+	}
+	return mc.calendarObjects()
+}
+
+func (b *MemoryBackend) QueryCalendarObjects(ctx context.Context, path string, query *CalendarQuery) ([]CalendarObject, error) {
+	cos, err := b.ListCalendarObjects(ctx, path, &query.CompRequest)
+	if err != nil {
+		return nil, err
+	}
+	return Filter(query, cos)
+}
+
+// checkConditionalMatch checks the If-Match and If-None-Match conditions in
+// opts against the ETag of the existing calendar object, which is empty if
+// the object doesn't exist.
+func checkConditionalMatch(opts *PutCalendarObjectOptions, etag string) error {
+	if opts == nil {
+		return nil
+	}
+	return internal.CheckConditionalMatch(string(opts.IfNoneMatch), string(opts.IfMatch), etag)
+}
+
+func (b *MemoryBackend) PutCalendarObject(ctx context.Context, path string, calendar *ical.Calendar, opts *PutCalendarObjectOptions) (*CalendarObject, bool, error) {
+	_, uid, err := ValidateCalendarObject(calendar)
+	if err != nil {
+		return nil, false, NewPreconditionError(PreconditionValidCalendarObjectResource)
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(calendar); err != nil {
+		return nil, false, NewPreconditionError(PreconditionValidCalendarData)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := internal.CleanPath(path)
+	mc, mo, _ := b.calendarObject(p)
+	if mc == nil {
+		return nil, false, internal.HTTPErrorf(http.StatusConflict, "caldav: no calendar at %q", path)
+	}
+	created := mo == nil
+	var etag string
+	if !created {
+		etag = mo.etag
+	}
+	if err := checkConditionalMatch(opts, etag); err != nil {
+		return nil, false, err
+	}
+
+	for otherPath, other := range mc.objects {
+		if otherPath != p && other.uid == uid {
+			return nil, false, NewUIDConflictError(otherPath)
+		}
+	}
+
+	mc.revision++
+	mo = &memoryObject{
+		uid:      uid,
+		data:     buf.Bytes(),
+		modTime:  time.Now().UTC(),
+		etag:     fmt.Sprintf("%x", sha1.Sum(buf.Bytes())),
+		revision: mc.revision,
+	}
+	mc.objects[p] = mo
+	delete(mc.tombstones, p)
+
+	co, err := mo.calendarObject(p)
+	return co, created, err
+}
+
+func (b *MemoryBackend) DeleteCalendarObject(ctx context.Context, path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := internal.CleanPath(path)
+	mc, _, err := b.calendarObject(p)
+	if err != nil {
+		return err
+	}
+
+	mc.revision++
+	delete(mc.objects, p)
+	mc.tombstones[p] = mc.revision
+	return nil
+}
+
+const memorySyncTokenPrefix = "data:,"
+
+func formatMemorySyncToken(revision int64) string {
+	return memorySyncTokenPrefix + strconv.FormatInt(revision, 10)
+}
+
+func parseMemorySyncToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	rev, err := strconv.ParseInt(strings.TrimPrefix(token, memorySyncTokenPrefix), 10, 64)
+	if err != nil || !strings.HasPrefix(token, memorySyncTokenPrefix) {
+		return 0, NewInvalidSyncTokenError()
+	}
+	return rev, nil
+}
+
+func (b *MemoryBackend) CalendarSyncToken(ctx context.Context, path string) (string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	mc, err := b.calendar(path)
+	if err != nil {
+		return "", err
+	}
+	return formatMemorySyncToken(mc.revision), nil
+}
+
+func (b *MemoryBackend) SyncCalendarObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	mc, err := b.calendar(path)
+	if err != nil {
+		return nil, err
+	}
+
+	since, err := parseMemorySyncToken(query.SyncToken)
+	if err != nil {
+		return nil, err
+	}
+	if since > mc.revision {
+		return nil, NewInvalidSyncTokenError()
+	}
+
+	type change struct {
+		path     string
+		revision int64
+		deleted  bool
+	}
+	var changes []change
+	for p, mo := range mc.objects {
+		if mo.revision > since {
+			changes = append(changes, change{path: p, revision: mo.revision})
+		}
+	}
+	// Deletions are irrelevant to a client performing an initial sync
+	if since > 0 {
+		for p, rev := range mc.tombstones {
+			if rev > since {
+				changes = append(changes, change{path: p, revision: rev, deleted: true})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].revision < changes[j].revision
+	})
+
+	resp := &SyncResponse{SyncToken: formatMemorySyncToken(mc.revision)}
+	if query.Limit > 0 && len(changes) > query.Limit {
+		changes = changes[:query.Limit]
+		resp.SyncToken = formatMemorySyncToken(changes[len(changes)-1].revision)
+		resp.Truncated = true
+	}
+
+	for _, c := range changes {
+		if c.deleted {
+			resp.Deleted = append(resp.Deleted, c.path)
+			continue
+		}
+		co, err := mc.objects[c.path].calendarObject(c.path)
+		if err != nil {
+			return nil, err
+		}
+		resp.Updated = append(resp.Updated, *co)
+	}
+	return resp, nil
+}
@@ -0,0 +1,135 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	webdav "github.com/trvita/caldav-client-yandex"
+	"github.com/trvita/caldav-client-yandex/internal"
+	"github.com/trvita/go-ical"
+)
+
+func newTestCalendar(uid, summary string, start time.Time) *ical.Calendar {
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, uid)
+	event.Props.SetDateTime(ical.PropDateTimeStamp, start)
+	event.Props.SetDateTime(ical.PropDateTimeStart, start)
+	event.Props.SetDateTime(ical.PropDateTimeEnd, start.Add(time.Hour))
+	event.Props.SetText(ical.PropSummary, summary)
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+	cal.Children = append(cal.Children, event.Component)
+	return cal
+}
+
+func httpErrorCode(err error) int {
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return 0
+}
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	for _, p := range []string{"/user/calendars/a/", "/user/calendars/b"} {
+		if err := b.CreateCalendar(ctx, &Calendar{Path: p}); err != nil {
+			t.Fatalf("CreateCalendar(%q) = %v", p, err)
+		}
+	}
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a"}); httpErrorCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("CreateCalendar() on existing calendar = %v, expected 405", err)
+	}
+
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	co, created, err := b.PutCalendarObject(ctx, "/user/calendars/a/1.ics", newTestCalendar("1", "Event #1", start), &PutCalendarObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch("*"),
+	})
+	if err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+	if !created {
+		t.Errorf("PutCalendarObject() of new object reported an update")
+	}
+	if co.ETag == "" || co.ContentLength == 0 {
+		t.Errorf("PutCalendarObject() returned incomplete object: %+v", co)
+	}
+
+	_, _, err = b.PutCalendarObject(ctx, "/user/calendars/a/1.ics", newTestCalendar("1", "Event #1", start), &PutCalendarObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch("*"),
+	})
+	if httpErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("PutCalendarObject() with If-None-Match on existing object = %v, expected 412", err)
+	}
+	_, _, err = b.PutCalendarObject(ctx, "/user/calendars/a/1.ics", newTestCalendar("1", "Event #1 bis", start), &PutCalendarObjectOptions{
+		IfMatch: webdav.ConditionalMatch(`"outdated"`),
+	})
+	if httpErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("PutCalendarObject() with stale If-Match = %v, expected 412", err)
+	}
+	_, created, err = b.PutCalendarObject(ctx, "/user/calendars/a/1.ics", newTestCalendar("1", "Event #1 bis", start), &PutCalendarObjectOptions{
+		IfMatch: webdav.ConditionalMatch(internal.ETag(co.ETag).String()),
+	})
+	if err != nil {
+		t.Errorf("PutCalendarObject() with matching If-Match = %v", err)
+	} else if created {
+		t.Errorf("PutCalendarObject() of existing object reported a creation")
+	}
+
+	_, _, err = b.PutCalendarObject(ctx, "/user/calendars/a/dup.ics", newTestCalendar("1", "Duplicate", start), nil)
+	if httpErrorCode(err) != http.StatusConflict {
+		t.Errorf("PutCalendarObject() with duplicate UID = %v, expected 409", err)
+	}
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/b/dup.ics", newTestCalendar("1", "Other calendar", start), nil); err != nil {
+		t.Errorf("PutCalendarObject() with UID from another calendar = %v", err)
+	}
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/c/1.ics", newTestCalendar("2", "No calendar", start), nil); httpErrorCode(err) != http.StatusConflict {
+		t.Errorf("PutCalendarObject() in missing calendar = %v, expected 409", err)
+	}
+
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/a/2.ics", newTestCalendar("2", "Event #2", start.Add(48*time.Hour)), nil); err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+	cos, err := b.QueryCalendarObjects(ctx, "/user/calendars/a/", &CalendarQuery{
+		CompFilter: CompFilter{
+			Name: "VCALENDAR",
+			Comps: []CompFilter{{
+				Name:  "VEVENT",
+				Start: start.Add(24 * time.Hour),
+				End:   start.Add(72 * time.Hour),
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("QueryCalendarObjects() = %v", err)
+	}
+	if len(cos) != 1 || cos[0].Path != "/user/calendars/a/2.ics" {
+		t.Errorf("QueryCalendarObjects() = %+v, expected only /user/calendars/a/2.ics", cos)
+	}
+
+	token, err := b.CalendarSyncToken(ctx, "/user/calendars/a/")
+	if err != nil {
+		t.Fatalf("CalendarSyncToken() = %v", err)
+	}
+	if err := b.DeleteCalendarObject(ctx, "/user/calendars/a/1.ics"); err != nil {
+		t.Fatalf("DeleteCalendarObject() = %v", err)
+	}
+	if _, err := b.GetCalendarObject(ctx, "/user/calendars/a/1.ics", &CalendarCompRequest{}); httpErrorCode(err) != http.StatusNotFound {
+		t.Errorf("GetCalendarObject() on deleted object = %v, expected 404", err)
+	}
+	sr, err := b.SyncCalendarObjects(ctx, "/user/calendars/a/", &SyncQuery{SyncToken: token})
+	if err != nil {
+		t.Fatalf("SyncCalendarObjects() = %v", err)
+	}
+	if len(sr.Updated) != 0 || len(sr.Deleted) != 1 || sr.Deleted[0] != "/user/calendars/a/1.ics" {
+		t.Errorf("SyncCalendarObjects() = %+v, expected a single deletion", sr)
+	}
+	if sr.SyncToken == token {
+		t.Errorf("SyncCalendarObjects() returned unchanged sync token %q", token)
+	}
+}
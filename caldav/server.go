@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"mime"
 	"net/http"
 	"path"
@@ -34,16 +35,43 @@ type Backend interface {
 	CreateCalendar(ctx context.Context, calendar *Calendar) error
 	ListCalendars(ctx context.Context) ([]Calendar, error)
 	GetCalendar(ctx context.Context, path string) (*Calendar, error)
+	DeleteCalendar(ctx context.Context, path string) error
 
 	GetCalendarObject(ctx context.Context, path string, req *CalendarCompRequest) (*CalendarObject, error)
 	ListCalendarObjects(ctx context.Context, path string, req *CalendarCompRequest) ([]CalendarObject, error)
 	QueryCalendarObjects(ctx context.Context, path string, query *CalendarQuery) ([]CalendarObject, error)
-	PutCalendarObject(ctx context.Context, path string, calendar *ical.Calendar, opts *PutCalendarObjectOptions) (*CalendarObject, error)
+	// PutCalendarObject stores a calendar object resource and reports whether
+	// it was created, rather than updated. The Handler checks that the UID
+	// isn't used by another object of the calendar beforehand, but can't do
+	// so atomically: backends serving concurrent requests should check again.
+	PutCalendarObject(ctx context.Context, path string, calendar *ical.Calendar, opts *PutCalendarObjectOptions) (co *CalendarObject, created bool, err error)
 	DeleteCalendarObject(ctx context.Context, path string) error
 
 	webdav.UserPrincipalBackend
 }
 
+// SyncBackend is an optional interface a Backend can implement to support
+// the sync-collection REPORT, as defined in RFC 6578.
+type SyncBackend interface {
+	// CalendarSyncToken returns the current sync token of a calendar.
+	CalendarSyncToken(ctx context.Context, path string) (string, error)
+	// SyncCalendarObjects returns the calendar objects changed and deleted
+	// since query.SyncToken, along with the new sync token. An empty
+	// query.SyncToken requests an initial synchronization. If query.Limit is
+	// positive, at most query.Limit changes must be returned and Truncated
+	// must be set if more are pending. An invalid or expired query.SyncToken
+	// must be reported with NewInvalidSyncTokenError.
+	SyncCalendarObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error)
+}
+
+// CalendarUpdateBackend is an optional interface a Backend can implement to
+// allow clients to change the properties of calendars with PROPPATCH.
+type CalendarUpdateBackend interface {
+	// UpdateCalendar changes the properties of the calendar at path. The
+	// update must be applied atomically.
+	UpdateCalendar(ctx context.Context, path string, update *CalendarUpdate) error
+}
+
 // Handler handles CalDAV HTTP requests. It can be used to create a CalDAV
 // server.
 type Handler struct {
@@ -73,6 +101,15 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "REPORT":
 		err = h.handleReport(w, r)
+	case "MKCALENDAR":
+		b := backend{
+			Backend: h.Backend,
+			Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+		}
+		err = b.Mkcalendar(r)
+		if err == nil {
+			w.WriteHeader(http.StatusCreated)
+		}
 	default:
 		b := backend{
 			Backend: h.Backend,
@@ -97,8 +134,12 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 		return h.handleQuery(r, w, report.Query)
 	} else if report.Multiget != nil {
 		return h.handleMultiget(r.Context(), w, report.Multiget)
+	} else if report.Sync != nil {
+		return h.handleSyncCollection(r, w, report.Sync)
+	} else if report.FreeBusy != nil {
+		return h.handleFreeBusyQuery(r, w, report.FreeBusy)
 	}
-	return internal.HTTPErrorf(http.StatusBadRequest, "caldav: expected calendar-query or calendar-multiget element in REPORT request")
+	return internal.HTTPErrorf(http.StatusBadRequest, "caldav: expected calendar-query, calendar-multiget, sync-collection or free-busy-query element in REPORT request")
 }
 
 func decodeParamFilter(el *paramFilter) (*ParamFilter, error) {
@@ -110,11 +151,26 @@ func decodeParamFilter(el *paramFilter) (*ParamFilter, error) {
 		pf.IsNotDefined = true
 	}
 	if el.TextMatch != nil {
-		pf.TextMatch = &TextMatch{Text: el.TextMatch.Text}
+		tm, err := decodeTextMatch(el.TextMatch)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatch = tm
 	}
 	return pf, nil
 }
 
+func decodeTextMatch(el *textMatch) (*TextMatch, error) {
+	if el.Collation != "" && !internal.IsSupportedCollation(el.Collation) {
+		return nil, NewPreconditionError(PreconditionSupportedCollation)
+	}
+	return &TextMatch{
+		Text:            el.Text,
+		NegateCondition: bool(el.NegateCondition),
+		Collation:       Collation(el.Collation),
+	}, nil
+}
+
 func decodePropFilter(el *propFilter) (*PropFilter, error) {
 	pf := &PropFilter{Name: el.Name}
 	if el.IsNotDefined != nil {
@@ -124,7 +180,11 @@ func decodePropFilter(el *propFilter) (*PropFilter, error) {
 		pf.IsNotDefined = true
 	}
 	if el.TextMatch != nil {
-		pf.TextMatch = &TextMatch{Text: el.TextMatch.Text}
+		tm, err := decodeTextMatch(el.TextMatch)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatch = tm
 	}
 	if el.TimeRange != nil {
 		pf.Start = time.Time(el.TimeRange.Start)
@@ -181,6 +241,7 @@ func decodeComp(comp *comp) (*CalendarCompRequest, error) {
 	}
 
 	req := &CalendarCompRequest{
+		Name:     comp.Name,
 		AllProps: comp.Allprop != nil,
 		AllComps: comp.Allcomp != nil,
 	}
@@ -198,18 +259,146 @@ func decodeComp(comp *comp) (*CalendarCompRequest, error) {
 }
 
 func decodeCalendarDataReq(calendarData *calendarDataReq) (*CalendarCompRequest, error) {
-	if calendarData.Comp == nil {
-		return &CalendarCompRequest{
-			AllProps: true,
-			AllComps: true,
-		}, nil
+	req := &CalendarCompRequest{
+		AllProps: true,
+		AllComps: true,
+	}
+	if calendarData.Comp != nil {
+		var err error
+		if req, err = decodeComp(calendarData.Comp); err != nil {
+			return nil, err
+		}
+	}
+
+	if calendarData.Expand != nil && calendarData.LimitRecurrenceSet != nil {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: only one of expand or limit-recurrence-set can be specified in calendar-data")
+	}
+	if calendarData.Expand != nil {
+		expandReq, err := decodeExpandTimeRange(calendarData.Expand.Start, calendarData.Expand.End)
+		if err != nil {
+			return nil, err
+		}
+		req.Expand = expandReq
+	}
+	if calendarData.LimitRecurrenceSet != nil {
+		limitReq, err := decodeExpandTimeRange(calendarData.LimitRecurrenceSet.Start, calendarData.LimitRecurrenceSet.End)
+		if err != nil {
+			return nil, err
+		}
+		req.LimitRecurrenceSet = limitReq
+	}
+	if calendarData.LimitFreeBusySet != nil {
+		limitReq, err := decodeExpandTimeRange(calendarData.LimitFreeBusySet.Start, calendarData.LimitFreeBusySet.End)
+		if err != nil {
+			return nil, err
+		}
+		req.LimitFreeBusySet = limitReq
+	}
+	return req, nil
+}
+
+func decodeExpandTimeRange(start, end dateWithUTCTime) (*CalendarExpandRequest, error) {
+	req := &CalendarExpandRequest{
+		Start: time.Time(start),
+		End:   time.Time(end),
+	}
+	if req.Start.IsZero() || req.End.IsZero() || !req.End.After(req.Start) {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid time range in calendar-data")
+	}
+	return req, nil
+}
+
+// encodeCalendarData applies a calendar-data request to a calendar: the
+// recurrence and free-busy set options first, then the component and
+// property selection.
+func encodeCalendarData(cal *ical.Calendar, req *CalendarCompRequest) (*ical.Calendar, error) {
+	var err error
+	if req.Expand != nil {
+		cal, err = ExpandCalendar(cal, req.Expand.Start, req.Expand.End)
+	} else if req.LimitRecurrenceSet != nil {
+		cal, err = LimitRecurrenceSet(cal, req.LimitRecurrenceSet.Start, req.LimitRecurrenceSet.End)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.LimitFreeBusySet != nil {
+		if cal, err = LimitFreeBusySet(cal, req.LimitFreeBusySet.Start, req.LimitFreeBusySet.End); err != nil {
+			return nil, err
+		}
+	}
+
+	// An empty request selects the whole calendar
+	if req.Name == "" || !strings.EqualFold(req.Name, cal.Name) {
+		return cal, nil
 	}
-	return decodeComp(calendarData.Comp)
+	return &ical.Calendar{Component: filterComponent(req, cal.Component)}, nil
+}
+
+// requiredCompProps lists the properties kept in partial calendar data even
+// if they aren't requested, since the iCalendar encoder rejects components
+// without them.
+var requiredCompProps = map[string][]string{
+	ical.CompCalendar:         {ical.PropProductID, ical.PropVersion},
+	ical.CompEvent:            {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompToDo:             {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompJournal:          {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompFreeBusy:         {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompTimezone:         {ical.PropTimezoneID},
+	ical.CompTimezoneStandard: {ical.PropDateTimeStart, ical.PropTimezoneOffsetTo, ical.PropTimezoneOffsetFrom},
+	ical.CompTimezoneDaylight: {ical.PropDateTimeStart, ical.PropTimezoneOffsetTo, ical.PropTimezoneOffsetFrom},
+}
+
+// filterComponent returns a copy of comp with only the properties and child
+// components selected by req, as described in RFC 4791 section 9.6.1.
+func filterComponent(req *CalendarCompRequest, comp *ical.Component) *ical.Component {
+	out := ical.NewComponent(comp.Name)
+	if req.AllProps {
+		for name, props := range comp.Props {
+			out.Props[name] = props
+		}
+	} else {
+		for _, name := range req.Props {
+			name = strings.ToUpper(name)
+			if props, ok := comp.Props[name]; ok {
+				out.Props[name] = props
+			}
+		}
+		for _, name := range requiredCompProps[comp.Name] {
+			if props, ok := comp.Props[name]; ok {
+				out.Props[name] = props
+			}
+		}
+	}
+
+	if req.AllComps {
+		out.Children = comp.Children
+		return out
+	}
+	for _, child := range comp.Children {
+		for i := range req.Comps {
+			if strings.EqualFold(req.Comps[i].Name, child.Name) {
+				out.Children = append(out.Children, filterComponent(&req.Comps[i], child))
+				break
+			}
+		}
+	}
+	return out
 }
 
 func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *calendarQuery) error {
 	var q CalendarQuery
-	// TODO: calendar-data in query.Prop
+	if query.Prop != nil {
+		var calendarData calendarDataReq
+		if err := query.Prop.Decode(&calendarData); err != nil && !internal.IsNotFound(err) {
+			return err
+		} else if err == nil {
+			dataReq, err := decodeCalendarDataReq(&calendarData)
+			if err != nil {
+				return err
+			}
+			q.CompRequest = *dataReq
+		}
+	}
 	cf, err := decodeCompFilter(&query.Filter.CompFilter)
 	if err != nil {
 		return err
@@ -244,6 +433,42 @@ func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *cal
 	return internal.ServeMultiStatus(w, ms)
 }
 
+func (h *Handler) handleFreeBusyQuery(r *http.Request, w http.ResponseWriter, query *freeBusyQuery) error {
+	start, end := time.Time(query.TimeRange.Start), time.Time(query.TimeRange.End)
+	if start.IsZero() || end.IsZero() || !end.After(start) {
+		return internal.HTTPErrorf(http.StatusBadRequest, "caldav: free-busy-query requires a time-range with a start before its end")
+	}
+
+	q := CalendarQuery{
+		CompFilter: CompFilter{
+			Name: ical.CompCalendar,
+			Comps: []CompFilter{{
+				Name:  ical.CompEvent,
+				Start: start,
+				End:   end,
+			}},
+		},
+	}
+	cos, err := h.Backend.QueryCalendarObjects(r.Context(), r.URL.Path, &q)
+	if err != nil {
+		return err
+	}
+
+	fb, err := computeFreeBusy(cos, start, end)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(encodeFreeBusy(fb)); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ical.MIMEType)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
 func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, multiget *calendarMultiget) error {
 	var dataReq CalendarCompRequest
 	if multiget.Prop != nil {
@@ -287,6 +512,61 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 	return internal.ServeMultiStatus(w, ms)
 }
 
+func (h *Handler) handleSyncCollection(r *http.Request, w http.ResponseWriter, sync *internal.SyncCollectionQuery) error {
+	syncBackend, ok := h.Backend.(SyncBackend)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusNotImplemented, "caldav: sync-collection not supported")
+	}
+
+	switch sync.SyncLevel {
+	case "1", "infinite":
+		// calendars don't contain nested collections
+	default:
+		return internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid sync-level %q", sync.SyncLevel)
+	}
+
+	q := SyncQuery{SyncToken: sync.SyncToken}
+	if sync.Prop != nil {
+		var calendarData calendarDataReq
+		if err := sync.Prop.Decode(&calendarData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		decoded, err := decodeCalendarDataReq(&calendarData)
+		if err != nil {
+			return err
+		}
+		q.CompRequest = *decoded
+	}
+	if sync.Limit != nil {
+		q.Limit = int(sync.Limit.NResults)
+	}
+
+	sr, err := syncBackend.SyncCalendarObjects(r.Context(), r.URL.Path, &q)
+	if err != nil {
+		return err
+	}
+
+	b := backend{
+		Backend: h.Backend,
+		Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+	}
+	propfind := internal.PropFind{Prop: sync.Prop}
+	if propfind.Prop == nil {
+		propfind.Prop = &internal.Prop{}
+	}
+
+	var resps []internal.Response
+	for _, co := range sr.Updated {
+		resp, err := b.propFindCalendarObject(r.Context(), &propfind, &co)
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+	}
+
+	return internal.ServeSyncCollection(w, r.URL.Path, resps, sr.Deleted, sr.Truncated, sr.SyncToken)
+}
+
 type backend struct {
 	Backend Backend
 	Prefix  string
@@ -318,7 +598,7 @@ func (b *backend) Options(r *http.Request) (caps []string, allow []string, err e
 	caps = []string{"calendar-access"}
 
 	if b.resourceTypeAtPath(r.URL.Path) != resourceTypeCalendarObject {
-		return caps, []string{http.MethodOptions, "PROPFIND", "REPORT", "DELETE", "MKCOL"}, nil
+		return caps, []string{http.MethodOptions, "PROPFIND", "REPORT", "DELETE", "MKCOL", "MKCALENDAR"}, nil
 	}
 
 	var dataReq CalendarCompRequest
@@ -568,13 +848,32 @@ func (b *backend) propFindCalendar(ctx context.Context, propfind *internal.PropF
 			return &calendarDescription{Description: cal.Description}, nil
 		}
 	}
+	if cal.Color != "" {
+		props[calendarColorName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &calendarColor{Color: cal.Color}, nil
+		}
+	}
+	if cal.Timezone != "" {
+		props[calendarTimezoneName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &calendarTimezone{Data: cal.Timezone}, nil
+		}
+	}
 	if cal.MaxResourceSize > 0 {
 		props[maxResourceSizeName] = func(*internal.RawXMLValue) (interface{}, error) {
 			return &maxResourceSize{Size: cal.MaxResourceSize}, nil
 		}
 	}
+	if syncBackend, ok := b.Backend.(SyncBackend); ok {
+		props[internal.SyncTokenName] = func(*internal.RawXMLValue) (interface{}, error) {
+			token, err := syncBackend.CalendarSyncToken(ctx, cal.Path)
+			if err != nil {
+				return nil, err
+			}
+			return &internal.SyncToken{Token: token}, nil
+		}
+	}
 
-	// TODO: CALDAV:calendar-timezone, CALDAV:supported-calendar-component-set, CALDAV:min-date-time, CALDAV:max-date-time, CALDAV:max-instances, CALDAV:max-attendees-per-instance
+	// TODO: CALDAV:min-date-time, CALDAV:max-date-time, CALDAV:max-instances, CALDAV:max-attendees-per-instance
 
 	return internal.NewPropFindResponse(cal.Path, propfind, props)
 }
@@ -616,9 +915,22 @@ func (b *backend) propFindCalendarObject(ctx context.Context, propfind *internal
 			return &internal.GetContentType{Type: ical.MIMEType}, nil
 		},
 		// TODO: calendar-data can only be used in REPORT requests
-		calendarDataName: func(*internal.RawXMLValue) (interface{}, error) {
+		calendarDataName: func(raw *internal.RawXMLValue) (interface{}, error) {
+			var dataReq calendarDataReq
+			if err := raw.Decode(&dataReq); err != nil {
+				return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid calendar-data request: %v", err)
+			}
+			req, err := decodeCalendarDataReq(&dataReq)
+			if err != nil {
+				return nil, err
+			}
+			cal, err := encodeCalendarData(co.Data, req)
+			if err != nil {
+				return nil, err
+			}
+
 			var buf bytes.Buffer
-			if err := ical.NewEncoder(&buf).Encode(co.Data); err != nil {
+			if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
 				return nil, err
 			}
 
@@ -665,7 +977,119 @@ func (b *backend) propFindAllCalendarObjects(ctx context.Context, propfind *inte
 }
 
 func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
-	return nil, internal.HTTPErrorf(http.StatusNotImplemented, "caldav: PropPatch not implemented")
+	type propChange struct {
+		name xml.Name
+		raw  *internal.RawXMLValue // nil for removals
+	}
+	var changes []propChange
+	for _, set := range update.Set {
+		for i := range set.Prop.Raw {
+			name, _ := set.Prop.Raw[i].XMLName()
+			changes = append(changes, propChange{name, &set.Prop.Raw[i]})
+		}
+	}
+	for _, remove := range update.Remove {
+		for _, raw := range remove.Prop.Raw {
+			name, _ := raw.XMLName()
+			changes = append(changes, propChange{name, nil})
+		}
+	}
+
+	resp := internal.NewOKResponse(r.URL.Path)
+
+	updateBackend, ok := b.Backend.(CalendarUpdateBackend)
+	if !ok || b.resourceTypeAtPath(r.URL.Path) != resourceTypeCalendar {
+		for _, change := range changes {
+			if err := resp.EncodeProp(http.StatusForbidden, internal.NewRawXMLElement(change.name, nil, nil)); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+
+	var (
+		calUpdate CalendarUpdate
+		codes     = make([]int, len(changes))
+		failed    bool
+	)
+	for i, change := range changes {
+		codes[i] = http.StatusOK
+		if err := decodeCalendarUpdateProp(&calUpdate, change.name, change.raw); err != nil {
+			codes[i] = internal.HTTPErrorFromError(err).Code
+			failed = true
+		}
+	}
+
+	if failed {
+		// PROPPATCH is atomic: valid changes aren't applied either
+		for i := range codes {
+			if codes[i] == http.StatusOK {
+				codes[i] = http.StatusFailedDependency
+			}
+		}
+	} else if err := updateBackend.UpdateCalendar(r.Context(), r.URL.Path, &calUpdate); err != nil {
+		return nil, err
+	}
+
+	for i, change := range changes {
+		if err := resp.EncodeProp(codes[i], internal.NewRawXMLElement(change.name, nil, nil)); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// decodeCalendarUpdateProp adds the change to the property name to update.
+// If raw is nil, the property is removed.
+func decodeCalendarUpdateProp(update *CalendarUpdate, name xml.Name, raw *internal.RawXMLValue) error {
+	var (
+		v    interface{}
+		dest **string
+	)
+	switch name {
+	case internal.DisplayNameName:
+		v, dest = &internal.DisplayName{}, &update.Name
+	case calendarDescriptionName:
+		v, dest = &calendarDescription{}, &update.Description
+	case calendarColorName:
+		v, dest = &calendarColor{}, &update.Color
+	case calendarTimezoneName:
+		v, dest = &calendarTimezone{}, &update.Timezone
+	default:
+		return internal.HTTPErrorf(http.StatusForbidden, "caldav: property %q can't be changed", name.Local)
+	}
+
+	var s string
+	if raw != nil {
+		if err := raw.Decode(v); err != nil {
+			return internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid property %q: %v", name.Local, err)
+		}
+		switch v := v.(type) {
+		case *internal.DisplayName:
+			s = v.Name
+		case *calendarDescription:
+			s = v.Description
+		case *calendarColor:
+			s = v.Color
+		case *calendarTimezone:
+			s = v.Data
+			if err := validateCalendarTimezone(s); err != nil {
+				return err
+			}
+		}
+	}
+	*dest = &s
+	return nil
+}
+
+// validateCalendarTimezone checks that tz is an iCalendar object containing
+// exactly one VTIMEZONE, as required by RFC 4791 section 5.2.2.
+func validateCalendarTimezone(tz string) error {
+	cal, err := ical.NewDecoder(strings.NewReader(tz)).Decode()
+	if err != nil || len(cal.Children) != 1 || cal.Children[0].Name != ical.CompTimezone {
+		return NewPreconditionError(PreconditionValidCalendarData)
+	}
+	return nil
 }
 
 func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
@@ -682,18 +1106,49 @@ func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
 		return internal.HTTPErrorf(http.StatusBadRequest, "caldav: malformed Content-Type: %v", err)
 	}
 	if t != ical.MIMEType {
-		// TODO: send CALDAV:supported-calendar-data error
-		return internal.HTTPErrorf(http.StatusBadRequest, "caldav: unsupported Content-Type %q", t)
+		return NewPreconditionError(PreconditionSupportedCalendarData)
+	}
+
+	calPath := path.Dir(path.Clean(r.URL.Path)) + "/"
+	calendar, err := b.Backend.GetCalendar(r.Context(), calPath)
+	if internal.IsNotFound(err) {
+		return internal.HTTPErrorf(http.StatusConflict, "caldav: no calendar at %q", calPath)
+	} else if err != nil {
+		return err
 	}
 
-	// TODO: check CALDAV:max-resource-size precondition
-	cal, err := ical.NewDecoder(r.Body).Decode()
+	body := io.Reader(r.Body)
+	if calendar.MaxResourceSize > 0 {
+		if r.ContentLength > calendar.MaxResourceSize {
+			return NewPreconditionError(PreconditionMaxResourceSize)
+		}
+		data, err := io.ReadAll(io.LimitReader(r.Body, calendar.MaxResourceSize+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) > calendar.MaxResourceSize {
+			return NewPreconditionError(PreconditionMaxResourceSize)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	cal, err := ical.NewDecoder(body).Decode()
 	if err != nil {
-		// TODO: send CALDAV:valid-calendar-data error
-		return internal.HTTPErrorf(http.StatusBadRequest, "caldav: failed to parse iCalendar: %v", err)
+		return NewPreconditionError(PreconditionValidCalendarData)
+	}
+
+	compType, uid, err := ValidateCalendarObject(cal)
+	if err != nil || compType == "" || uid == "" {
+		return NewPreconditionError(PreconditionValidCalendarObjectResource)
+	}
+	if !isSupportedComponent(calendar, compType) {
+		return NewPreconditionError(PreconditionSupportedCalendarComponent)
+	}
+	if err := b.checkUIDConflict(r.Context(), calPath, r.URL.Path, uid); err != nil {
+		return err
 	}
 
-	co, err := b.Backend.PutCalendarObject(r.Context(), r.URL.Path, cal, &opts)
+	co, created, err := b.Backend.PutCalendarObject(r.Context(), r.URL.Path, cal, &opts)
 	if err != nil {
 		return err
 	}
@@ -704,18 +1159,66 @@ func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
 	if !co.ModTime.IsZero() {
 		w.Header().Set("Last-Modified", co.ModTime.UTC().Format(http.TimeFormat))
 	}
-	if co.Path != "" {
-		w.Header().Set("Location", co.Path)
+
+	if created {
+		if co.Path != "" {
+			w.Header().Set("Location", co.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return nil
+}
+
+// isSupportedComponent reports whether calendar accepts components named
+// compType. An empty supported component set accepts every component.
+func isSupportedComponent(calendar *Calendar, compType string) bool {
+	if len(calendar.SupportedComponentSet) == 0 {
+		return true
+	}
+	for _, name := range calendar.SupportedComponentSet {
+		if strings.EqualFold(name, compType) {
+			return true
+		}
 	}
+	return false
+}
 
-	// TODO: http.StatusNoContent if the resource already existed
-	w.WriteHeader(http.StatusCreated)
+// checkUIDConflict returns a no-uid-conflict precondition error if another
+// calendar object resource in the calendar at calPath uses uid. UIDs are
+// unique in a calendar collection regardless of the component type.
+func (b *backend) checkUIDConflict(ctx context.Context, calPath, objPath, uid string) error {
+	cos, err := b.Backend.ListCalendarObjects(ctx, calPath, &CalendarCompRequest{
+		Name:     ical.CompCalendar,
+		AllProps: true,
+		AllComps: true,
+	})
+	if err != nil {
+		return err
+	}
 
+	objPath = path.Clean(objPath)
+	for _, co := range cos {
+		if path.Clean(co.Path) == objPath {
+			continue
+		}
+		if _, otherUID, err := ValidateCalendarObject(co.Data); err == nil && otherUID == uid {
+			return NewUIDConflictError(co.Path)
+		}
+	}
 	return nil
 }
 
 func (b *backend) Delete(r *http.Request) error {
-	return b.Backend.DeleteCalendarObject(r.Context(), r.URL.Path)
+	switch b.resourceTypeAtPath(r.URL.Path) {
+	case resourceTypeCalendar:
+		return b.Backend.DeleteCalendar(r.Context(), r.URL.Path)
+	case resourceTypeCalendarObject:
+		return b.Backend.DeleteCalendarObject(r.Context(), r.URL.Path)
+	}
+	return internal.HTTPErrorf(http.StatusForbidden, "caldav: cannot delete resource at given location")
 }
 
 func (b *backend) Mkcol(r *http.Request) error {
@@ -730,19 +1233,81 @@ func (b *backend) Mkcol(r *http.Request) error {
 	if !internal.IsRequestBodyEmpty(r) {
 		var m mkcolReq
 		if err := internal.DecodeXMLRequest(r, &m); err != nil {
-			return internal.HTTPErrorf(http.StatusBadRequest, "carddav: error parsing mkcol request: %s", err.Error())
+			return internal.HTTPErrorf(http.StatusBadRequest, "caldav: error parsing mkcol request: %s", err.Error())
+		}
+		if m.Set == nil {
+			return internal.HTTPErrorf(http.StatusBadRequest, "caldav: missing resource type in mkcol request")
 		}
 
-		if !m.ResourceType.Is(internal.CollectionName) || !m.ResourceType.Is(calendarName) {
-			return internal.HTTPErrorf(http.StatusBadRequest, "carddav: unexpected resource type")
+		var resType internal.ResourceType
+		if err := m.Set.Prop.Decode(&resType); err != nil || !resType.Is(internal.CollectionName) || !resType.Is(calendarName) {
+			return internal.HTTPErrorf(http.StatusBadRequest, "caldav: unexpected resource type")
+		}
+		if err := decodeCalendarProp(&cal, &m.Set.Prop); err != nil {
+			return err
 		}
-		cal.Name = m.DisplayName
-		// TODO ...
 	}
 
 	return b.Backend.CreateCalendar(r.Context(), &cal)
 }
 
+// Mkcalendar creates a calendar, like an extended MKCOL request without an
+// explicit resource type.
+func (b *backend) Mkcalendar(r *http.Request) error {
+	if b.resourceTypeAtPath(r.URL.Path) != resourceTypeCalendar {
+		return internal.HTTPErrorf(http.StatusForbidden, "caldav: calendar creation not allowed at given location")
+	}
+
+	cal := Calendar{
+		Path: r.URL.Path,
+	}
+
+	if !internal.IsRequestBodyEmpty(r) {
+		var m mkcalendarReq
+		if err := internal.DecodeXMLRequest(r, &m); err != nil {
+			return internal.HTTPErrorf(http.StatusBadRequest, "caldav: error parsing mkcalendar request: %s", err.Error())
+		}
+		if m.Set != nil {
+			if err := decodeCalendarProp(&cal, &m.Set.Prop); err != nil {
+				return err
+			}
+		}
+	}
+
+	return b.Backend.CreateCalendar(r.Context(), &cal)
+}
+
+// decodeCalendarProp fills cal with the properties set by a MKCOL or
+// MKCALENDAR request.
+func decodeCalendarProp(cal *Calendar, prop *internal.Prop) error {
+	var (
+		dispName internal.DisplayName
+		desc     calendarDescription
+		color    calendarColor
+		tz       calendarTimezone
+		compSet  supportedCalendarComponentSet
+	)
+	for _, v := range []interface{}{&dispName, &desc, &color, &tz, &compSet} {
+		if err := prop.Decode(v); err != nil && !internal.IsNotFound(err) {
+			return internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid property: %v", err)
+		}
+	}
+
+	cal.Name = dispName.Name
+	cal.Description = desc.Description
+	cal.Color = color.Color
+	if tz.Data != "" {
+		if err := validateCalendarTimezone(tz.Data); err != nil {
+			return err
+		}
+	}
+	cal.Timezone = tz.Data
+	for _, c := range compSet.Comp {
+		cal.SupportedComponentSet = append(cal.SupportedComponentSet, c.Name)
+	}
+	return nil
+}
+
 func (b *backend) Copy(r *http.Request, dest *internal.Href, recursive, overwrite bool) (created bool, err error) {
 	return false, internal.HTTPErrorf(http.StatusNotImplemented, "caldav: Copy not implemented")
 }
@@ -766,15 +1331,27 @@ const (
 	PreconditionMaxDateTime                  PreconditionType = "max-date-time"
 	PreconditionMaxInstances                 PreconditionType = "max-instances"
 	PreconditionMaxAttendeesPerInstance      PreconditionType = "max-attendees-per-instance"
+	PreconditionSupportedCollation           PreconditionType = "supported-collation"
 )
 
 func NewPreconditionError(err PreconditionType) error {
 	name := xml.Name{Space: "urn:ietf:params:xml:ns:caldav", Local: string(err)}
-	elem := internal.NewRawXMLElement(name, nil, nil)
-	return &internal.HTTPError{
-		Code: 409,
-		Err: &internal.Error{
-			Raw: []internal.RawXMLValue{*elem},
-		},
+	return internal.NewPreconditionError(internal.NewRawXMLElement(name, nil, nil))
+}
+
+// NewInvalidSyncTokenError returns the error SyncCalendarObjects must return when
+// the sync token is invalid or has expired, asking the client to restart
+// with an initial synchronization.
+func NewInvalidSyncTokenError() error {
+	return internal.NewInvalidSyncTokenError()
+}
+
+// NewUIDConflictError returns a no-uid-conflict precondition error, reporting
+// the path of the calendar object resource already using the UID.
+func NewUIDConflictError(href string) error {
+	elem, err := internal.EncodeRawXMLElement(&noUIDConflict{Href: internal.Href{Path: href}})
+	if err != nil {
+		return err
 	}
+	return internal.NewPreconditionError(elem)
 }
@@ -1,10 +1,12 @@
 package caldav
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -186,6 +188,10 @@ func (t testBackend) CreateCalendar(ctx context.Context, calendar *Calendar) err
 	return nil
 }
 
+func (t testBackend) DeleteCalendar(ctx context.Context, path string) error {
+	return nil
+}
+
 func (t testBackend) ListCalendars(ctx context.Context) ([]Calendar, error) {
 	return t.calendars, nil
 }
@@ -222,8 +228,8 @@ func (t testBackend) GetCalendarObject(ctx context.Context, path string, req *Ca
 	return nil, fmt.Errorf("Couldn't find calendar object at: %s", path)
 }
 
-func (t testBackend) PutCalendarObject(ctx context.Context, path string, calendar *ical.Calendar, opts *PutCalendarObjectOptions) (*CalendarObject, error) {
-	return nil, nil
+func (t testBackend) PutCalendarObject(ctx context.Context, path string, calendar *ical.Calendar, opts *PutCalendarObjectOptions) (*CalendarObject, bool, error) {
+	return nil, false, nil
 }
 
 func (t testBackend) ListCalendarObjects(ctx context.Context, path string, req *CalendarCompRequest) ([]CalendarObject, error) {
@@ -233,3 +239,391 @@ func (t testBackend) ListCalendarObjects(ctx context.Context, path string, req *
 func (t testBackend) QueryCalendarObjects(ctx context.Context, path string, query *CalendarQuery) ([]CalendarObject, error) {
 	return nil, nil
 }
+
+type testSyncBackend struct {
+	testBackend
+}
+
+func (t testSyncBackend) CalendarSyncToken(ctx context.Context, path string) (string, error) {
+	return "http://example.com/ns/sync/2", nil
+}
+
+func (t testSyncBackend) SyncCalendarObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	if query.SyncToken != "http://example.com/ns/sync/1" {
+		return nil, fmt.Errorf("unexpected sync token %q", query.SyncToken)
+	}
+	resp := &SyncResponse{
+		SyncToken: "http://example.com/ns/sync/2",
+		Updated:   t.objectMap[path],
+		Deleted:   []string{path + "deleted.ics"},
+	}
+	if query.Limit > 0 && len(resp.Updated) > query.Limit {
+		resp.Updated = resp.Updated[:query.Limit]
+		resp.Truncated = true
+	}
+	return resp, nil
+}
+
+func TestSyncCollection(t *testing.T) {
+	calendar := Calendar{Path: "/user/calendars/a/"}
+	var objects []CalendarObject
+	for _, name := range []string{"first", "second"} {
+		cal := ical.NewCalendar()
+		cal.Props.SetText(ical.PropVersion, "2.0")
+		cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+		event := ical.NewEvent()
+		event.Props.SetText(ical.PropUID, name)
+		event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+		cal.Children = append(cal.Children, event.Component)
+		objects = append(objects, CalendarObject{
+			Path: calendar.Path + name + ".ics",
+			ETag: name + "-etag",
+			Data: cal,
+		})
+	}
+
+	handler := Handler{Backend: testSyncBackend{testBackend{
+		calendars: []Calendar{calendar},
+		objectMap: map[string][]CalendarObject{calendar.Path: objects},
+	}}}
+	ts := httptest.NewServer(&handler)
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+	resp, err := client.SyncCollection(context.Background(), calendar.Path, &SyncQuery{
+		SyncToken: "http://example.com/ns/sync/1",
+		Limit:     1,
+	})
+	if err != nil {
+		t.Fatalf("SyncCollection() = %v", err)
+	}
+	if resp.SyncToken != "http://example.com/ns/sync/2" {
+		t.Errorf("SyncToken = %q, expected %q", resp.SyncToken, "http://example.com/ns/sync/2")
+	}
+	if len(resp.Updated) != 1 || resp.Updated[0].Path != objects[0].Path || resp.Updated[0].ETag != objects[0].ETag {
+		t.Errorf("Updated = %+v, expected only %v", resp.Updated, objects[0].Path)
+	}
+	if len(resp.Deleted) != 1 || resp.Deleted[0] != calendar.Path+"deleted.ics" {
+		t.Errorf("Deleted = %v, expected [%vdeleted.ics]", resp.Deleted, calendar.Path)
+	}
+	if !resp.Truncated {
+		t.Errorf("Truncated = false, expected true")
+	}
+
+	req := httptest.NewRequest("PROPFIND", calendar.Path, strings.NewReader(`<d:propfind xmlns:d="DAV:"><d:prop><d:sync-token/></d:prop></d:propfind>`))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Depth", "0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	data, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<sync-token xmlns="DAV:">http://example.com/ns/sync/2</sync-token>`) {
+		t.Errorf("sync-token not returned in PROPFIND, response:\n%s", data)
+	}
+}
+
+func TestSyncCollectionInvalidToken(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	handler := Handler{Backend: b}
+
+	for _, token := range []string{"garbage", formatMemorySyncToken(42)} {
+		req := httptest.NewRequest("REPORT", "/user/calendars/a/", strings.NewReader(`<d:sync-collection xmlns:d="DAV:">
+  <d:sync-token>`+token+`</d:sync-token>
+  <d:sync-level>1</d:sync-level>
+  <d:prop><d:getetag/></d:prop>
+</d:sync-collection>`))
+		req.Header.Set("Content-Type", "application/xml")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("sync-collection with token %q = %v, want %v", token, w.Code, http.StatusForbidden)
+		}
+		if want := `<valid-sync-token xmlns="DAV:">`; !strings.Contains(w.Body.String(), want) {
+			t.Errorf("sync-collection with token %q response does not contain %q:\n%s", token, want, w.Body.String())
+		}
+	}
+}
+
+func TestDeleteCalendar(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/a/1.ics", newTestCalendar("1", "Event #1", start), nil); err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+	handler := Handler{Backend: b}
+
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/user/calendars/", http.StatusForbidden},
+		{"/user/calendars/a/", http.StatusNoContent},
+		{"/user/calendars/a/", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, tc.path, nil))
+		if w.Code != tc.code {
+			t.Errorf("DELETE %v = %v, want %v", tc.path, w.Code, tc.code)
+		}
+	}
+
+	if cals, err := b.ListCalendars(ctx); err != nil || len(cals) != 0 {
+		t.Errorf("ListCalendars() = %+v, %v, want no calendars", cals, err)
+	}
+	if _, err := b.GetCalendarObject(ctx, "/user/calendars/a/1.ics", &CalendarCompRequest{}); httpErrorCode(err) != http.StatusNotFound {
+		t.Errorf("GetCalendarObject() in deleted calendar = %v, want 404", err)
+	}
+}
+
+func TestCreateCalendarInvalidTimezone(t *testing.T) {
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	handler := Handler{Backend: b}
+
+	prop := `<d:prop>
+      <c:calendar-timezone>BEGIN:VCALENDAR
+END:VCALENDAR
+</c:calendar-timezone>
+    </d:prop>`
+	for _, tc := range []struct {
+		method, body string
+	}{
+		{"MKCALENDAR", `<c:mkcalendar xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:set>
+    ` + prop + `
+  </d:set>
+</c:mkcalendar>`},
+		{"MKCOL", `<d:mkcol xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:set>
+    <d:prop><d:resourcetype><d:collection/><c:calendar/></d:resourcetype></d:prop>
+    ` + prop + `
+  </d:set>
+</d:mkcol>`},
+	} {
+		req := httptest.NewRequest(tc.method, "/user/calendars/a/", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/xml")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("%v with invalid timezone = %v, want %v", tc.method, w.Code, http.StatusConflict)
+		}
+		if want := `<valid-calendar-data xmlns="urn:ietf:params:xml:ns:caldav">`; !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%v with invalid timezone response does not contain %q:\n%s", tc.method, want, w.Body.String())
+		}
+	}
+
+	if cals, err := b.ListCalendars(context.Background()); err != nil || len(cals) != 0 {
+		t.Errorf("ListCalendars() = %+v, %v, want no calendars", cals, err)
+	}
+}
+
+func TestPutCalendarObjectPreconditions(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{
+		Path:                  "/user/calendars/a/",
+		MaxResourceSize:       512,
+		SupportedComponentSet: []string{ical.CompEvent},
+	}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	if _, _, err := b.PutCalendarObject(ctx, "/user/calendars/a/1.ics", newTestCalendar("1", "Event #1", start), nil); err != nil {
+		t.Fatalf("PutCalendarObject() = %v", err)
+	}
+	handler := Handler{Backend: b}
+
+	const todo = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTODO
+UID:2
+DTSTAMP:20060206T001121Z
+SUMMARY:Todo
+END:VTODO
+END:VCALENDAR
+`
+	const duplicate = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:1
+DTSTAMP:20060206T001121Z
+DTSTART:20060104T100000Z
+SUMMARY:Event #1
+END:VEVENT
+END:VCALENDAR
+`
+	large := strings.Replace(duplicate, "SUMMARY:Event #1", "SUMMARY:"+strings.Repeat("x", 512), 1)
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		precond     PreconditionType
+	}{
+		{"content type", "text/plain", duplicate, PreconditionSupportedCalendarData},
+		{"max size", ical.MIMEType, large, PreconditionMaxResourceSize},
+		{"invalid data", ical.MIMEType, "BEGIN:VCALENDAR\n", PreconditionValidCalendarData},
+		{"method", ical.MIMEType, strings.Replace(duplicate, "VERSION:2.0", "VERSION:2.0\nMETHOD:PUBLISH", 1), PreconditionValidCalendarObjectResource},
+		{"component", ical.MIMEType, todo, PreconditionSupportedCalendarComponent},
+		{"uid", ical.MIMEType, duplicate, PreconditionNoUIDConflict},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/user/calendars/a/2.ics", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("PUT = %v, want %v", w.Code, http.StatusConflict)
+			}
+			if want := "<" + string(tc.precond) + " "; !strings.Contains(w.Body.String(), want) {
+				t.Errorf("PUT response does not contain %q:\n%s", tc.precond, w.Body.String())
+			}
+		})
+	}
+
+	// Updating the object holding the UID is not a conflict
+	req := httptest.NewRequest(http.MethodPut, "/user/calendars/a/1.ics", strings.NewReader(duplicate))
+	req.Header.Set("Content-Type", ical.MIMEType)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code/100 != 2 {
+		t.Errorf("PUT to existing object = %v, want success:\n%s", w.Code, w.Body.String())
+	}
+}
+
+// testPutBackend stores objects without checking UIDs, leaving it to the
+// Handler.
+type testPutBackend struct {
+	testBackend
+	puts []string
+}
+
+func (t *testPutBackend) PutCalendarObject(ctx context.Context, path string, calendar *ical.Calendar, opts *PutCalendarObjectOptions) (*CalendarObject, bool, error) {
+	t.puts = append(t.puts, path)
+	return &CalendarObject{Path: path, Data: calendar}, false, nil
+}
+
+func TestPutCalendarObjectUIDConflict(t *testing.T) {
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	b := &testPutBackend{testBackend: testBackend{
+		calendars: []Calendar{{
+			Path:                  "/user/calendars/a/",
+			SupportedComponentSet: []string{ical.CompEvent, ical.CompToDo},
+		}},
+		objectMap: map[string][]CalendarObject{
+			"/user/calendars/a/": {
+				{Path: "/user/calendars/a/1.ics", Data: newTestCalendar("1", "Event #1", start)},
+				// Only exact UID matches conflict
+				{Path: "/user/calendars/a/10.ics", Data: newTestCalendar("10", "Event #10", start)},
+			},
+		},
+	}}
+	handler := Handler{Backend: b}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(newTestCalendar("1", "Event #1", start)); err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/user/calendars/a/2.ics", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Type", ical.MIMEType)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("PUT with duplicate UID = %v, want %v", w.Code, http.StatusConflict)
+	}
+	want := `<no-uid-conflict xmlns="urn:ietf:params:xml:ns:caldav"><href xmlns="DAV:">/user/calendars/a/1.ics</href></no-uid-conflict>`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("PUT response does not report the conflicting object:\n%s", w.Body.String())
+	}
+
+	// UIDs are unique across component types
+	todo := strings.NewReader(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTODO
+UID:1
+DTSTAMP:20060206T001121Z
+SUMMARY:Todo
+END:VTODO
+END:VCALENDAR
+`)
+	req = httptest.NewRequest(http.MethodPut, "/user/calendars/a/3.ics", todo)
+	req.Header.Set("Content-Type", ical.MIMEType)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), want) {
+		t.Errorf("PUT of a VTODO with an event's UID = %v, want %v:\n%s", w.Code, http.StatusConflict, w.Body.String())
+	}
+
+	if len(b.puts) != 0 {
+		t.Errorf("PUT with duplicate UID reached the backend: %v", b.puts)
+	}
+
+	// Updating the object holding the UID is not a conflict
+	req = httptest.NewRequest(http.MethodPut, "/user/calendars/a/1.ics", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Type", ical.MIMEType)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("PUT to existing object = %v, want %v:\n%s", w.Code, http.StatusNoContent, w.Body.String())
+	}
+	if len(b.puts) != 1 {
+		t.Errorf("PUT to existing object didn't reach the backend")
+	}
+}
+
+func TestPutCalendarObjectStatus(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend("/user/", "/user/calendars/")
+	if err := b.CreateCalendar(ctx, &Calendar{Path: "/user/calendars/a/"}); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	handler := Handler{Backend: b}
+
+	var buf bytes.Buffer
+	start := time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)
+	if err := ical.NewEncoder(&buf).Encode(newTestCalendar("1", "Event #1", start)); err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+
+	for _, tc := range []struct {
+		code     int
+		location string
+	}{
+		{http.StatusCreated, "/user/calendars/a/1.ics"},
+		{http.StatusNoContent, ""},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/user/calendars/a/1.ics", bytes.NewReader(buf.Bytes()))
+		req.Header.Set("Content-Type", ical.MIMEType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Errorf("PUT = %v, want %v", w.Code, tc.code)
+		}
+		if got := w.Header().Get("Location"); got != tc.location {
+			t.Errorf("PUT Location = %q, want %q", got, tc.location)
+		}
+		if w.Header().Get("ETag") == "" {
+			t.Errorf("PUT returned no ETag")
+		}
+	}
+}
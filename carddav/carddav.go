@@ -4,6 +4,7 @@
 package carddav
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/emersion/go-vcard"
@@ -30,6 +31,14 @@ type AddressBook struct {
 	SupportedAddressData []AddressDataType
 }
 
+// AddressBookUpdate describes changes to the properties of an address book.
+// Nil fields are left unchanged, and fields pointing to an empty string
+// remove the property.
+type AddressBookUpdate struct {
+	Name        *string
+	Description *string
+}
+
 func (ab *AddressBook) SupportsAddressData(contentType, version string) bool {
 	if len(ab.SupportedAddressData) == 0 {
 		return contentType == "text/vcard" && version == "3.0"
@@ -78,6 +87,7 @@ type TextMatch struct {
 	Text            string
 	NegateCondition bool
 	MatchType       MatchType // defaults to MatchContains
+	Collation       Collation // defaults to CollationUnicodeCasemap
 }
 
 type FilterTest string
@@ -96,6 +106,15 @@ const (
 	MatchEndsWith   MatchType = "ends-with"
 )
 
+// Collation is a text-match collation, as described in RFC 6352 section 8.3.
+type Collation string
+
+const (
+	CollationOctet          Collation = internal.CollationOctet
+	CollationASCIICasemap   Collation = internal.CollationASCIICasemap
+	CollationUnicodeCasemap Collation = internal.CollationUnicodeCasemap
+)
+
 type AddressBookMultiGet struct {
 	Paths       []string
 	DataRequest AddressDataRequest
@@ -121,4 +140,38 @@ type SyncResponse struct {
 	SyncToken string
 	Updated   []AddressObject
 	Deleted   []string
+	// Truncated is set if the result was limited and the client needs to
+	// issue another request with SyncToken to get the remaining changes
+	Truncated bool
+}
+
+// PreconditionError is returned by Client methods when the server rejects a
+// request because a precondition failed. For PreconditionNoUIDConflict, Href
+// is the path of the address object already using the UID, if the server
+// reported it.
+type PreconditionError struct {
+	Precondition PreconditionType
+	Href         string
+	Err          error
+}
+
+func (err *PreconditionError) Error() string {
+	if err.Href != "" {
+		return fmt.Sprintf("carddav: precondition %q failed: conflicts with %q", err.Precondition, err.Href)
+	}
+	return fmt.Sprintf("carddav: precondition %q failed", err.Precondition)
+}
+
+func (err *PreconditionError) Unwrap() error {
+	return err.Err
+}
+
+// newPreconditionError returns a *PreconditionError if err carries a
+// CARDDAV precondition element, and err unchanged otherwise.
+func newPreconditionError(err error) error {
+	name, href, ok := internal.DecodePrecondition(err, namespace)
+	if !ok {
+		return err
+	}
+	return &PreconditionError{Precondition: PreconditionType(name), Href: href, Err: err}
 }
@@ -2,7 +2,10 @@ package carddav
 
 import (
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,6 +13,7 @@ import (
 
 	"github.com/emersion/go-vcard"
 	"github.com/trvita/caldav-client-yandex"
+	"github.com/trvita/caldav-client-yandex/internal"
 )
 
 type testBackend struct {
@@ -111,7 +115,7 @@ func (*testBackend) QueryAddressObjects(ctx context.Context, path string, query
 	panic("TODO: implement")
 }
 
-func (*testBackend) PutAddressObject(ctx context.Context, path string, card vcard.Card, opts *PutAddressObjectOptions) (*AddressObject, error) {
+func (*testBackend) PutAddressObject(ctx context.Context, path string, card vcard.Card, opts *PutAddressObjectOptions) (*AddressObject, bool, error) {
 	panic("TODO: implement")
 }
 
@@ -240,3 +244,189 @@ func TestCreateAddressbookMinimalBody(t *testing.T) {
 		t.Fatalf("Address book sdscription is '%s', expected 'My primary address book.'", c.Description)
 	}
 }
+
+func TestClientPutAddressObjectPrecondition(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8" ?>
+<D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+  <C:no-uid-conflict><D:href>/addressbooks/user0/default/alice.vcf</D:href></C:no-uid-conflict>
+</D:error>`)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	card, err := vcard.NewDecoder(strings.NewReader(aliceData)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.PutAddressObject(context.Background(), "/addressbooks/user0/default/"+alicePath, card)
+	var precondErr *PreconditionError
+	if !errors.As(err, &precondErr) {
+		t.Fatalf("PutAddressObject() = %v, want PreconditionError", err)
+	}
+	if precondErr.Precondition != PreconditionNoUIDConflict {
+		t.Errorf("PreconditionError.Precondition = %q, want %q", precondErr.Precondition, PreconditionNoUIDConflict)
+	}
+	if precondErr.Href != "/addressbooks/user0/default/alice.vcf" {
+		t.Errorf("PreconditionError.Href = %q, want %q", precondErr.Href, "/addressbooks/user0/default/alice.vcf")
+	}
+}
+
+const propPatchFailedResponse = `<?xml version="1.0" encoding="utf-8" ?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:carddav">
+  <d:response>
+    <d:href>/addressbooks/user0/default/</d:href>
+    <d:propstat>
+      <d:prop><d:displayname/></d:prop>
+      <d:status>HTTP/1.1 403 Forbidden</d:status>
+      <d:responsedescription>Name is read-only</d:responsedescription>
+    </d:propstat>
+    <d:propstat>
+      <d:prop><c:addressbook-description/></d:prop>
+      <d:status>HTTP/1.1 424 Failed Dependency</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`
+
+func TestClientUpdateAddressBook(t *testing.T) {
+	var got internal.PropertyUpdate
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPPATCH" {
+			t.Errorf("method = %q, want PROPPATCH", r.Method)
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, propPatchFailedResponse)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	name, desc := "Contacts", ""
+	statuses, err := client.UpdateAddressBook(context.Background(), "/addressbooks/user0/default/", &AddressBookUpdate{
+		Name:        &name,
+		Description: &desc,
+	})
+	if err == nil || !strings.Contains(err.Error(), "displayname") || !strings.Contains(err.Error(), "Name is read-only") {
+		t.Errorf("UpdateAddressBook() error = %v, want displayname failure", err)
+	}
+
+	if len(got.Set) != 1 || len(got.Remove) != 1 {
+		t.Fatalf("request has %v set and %v remove elements, want 1 and 1", len(got.Set), len(got.Remove))
+	}
+	var dispName internal.DisplayName
+	if err := got.Set[0].Prop.Decode(&dispName); err != nil || dispName.Name != name {
+		t.Errorf("set displayname = %q, %v, want %q", dispName.Name, err, name)
+	}
+	if got.Remove[0].Prop.Get(addressBookDescriptionName) == nil {
+		t.Errorf("addressbook-description isn't removed")
+	}
+
+	want := map[xml.Name]int{
+		internal.DisplayNameName:   http.StatusForbidden,
+		addressBookDescriptionName: http.StatusFailedDependency,
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("got %v statuses, want %v", len(statuses), len(want))
+	}
+	for _, status := range statuses {
+		if status.Code != want[status.Name] || status.Err == nil {
+			t.Errorf("status of %v = %v, %v, want %v", status.Name.Local, status.Code, status.Err, want[status.Name])
+		}
+	}
+
+	if statuses, err := client.UpdateAddressBook(context.Background(), "/addressbooks/user0/default/", &AddressBookUpdate{}); err != nil || statuses != nil {
+		t.Errorf("UpdateAddressBook() without changes = %v, %v, want no request", statuses, err)
+	}
+}
+
+type testSyncBackend struct {
+	testBackend
+	objects []AddressObject
+}
+
+func (*testSyncBackend) AddressBookSyncToken(ctx context.Context, path string) (string, error) {
+	return "http://example.com/ns/sync/2", nil
+}
+
+func (b *testSyncBackend) SyncAddressObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	if query.SyncToken != "http://example.com/ns/sync/1" {
+		return nil, fmt.Errorf("unexpected sync token %q", query.SyncToken)
+	}
+	resp := &SyncResponse{
+		SyncToken: "http://example.com/ns/sync/2",
+		Updated:   b.objects,
+		Deleted:   []string{path + "deleted.vcf"},
+	}
+	if query.Limit > 0 && len(resp.Updated) > query.Limit {
+		resp.Updated = resp.Updated[:query.Limit]
+		resp.Truncated = true
+	}
+	return resp, nil
+}
+
+func TestSyncCollection(t *testing.T) {
+	const abPath = "/user/contacts/default/"
+	var objects []AddressObject
+	for _, name := range []string{"first", "second"} {
+		card := make(vcard.Card)
+		card.SetValue(vcard.FieldUID, name)
+		card.SetValue(vcard.FieldFormattedName, name)
+		objects = append(objects, AddressObject{
+			Path: abPath + name + ".vcf",
+			ETag: name + "-etag",
+			Card: card,
+		})
+	}
+
+	handler := Handler{Backend: &testSyncBackend{objects: objects}}
+	ts := httptest.NewServer(&handler)
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	for _, tc := range []struct {
+		limit     int
+		updated   int
+		truncated bool
+	}{
+		{0, 2, false},
+		{1, 1, true},
+		{5, 2, false},
+	} {
+		resp, err := client.SyncCollection(context.Background(), abPath, &SyncQuery{
+			SyncToken: "http://example.com/ns/sync/1",
+			Limit:     tc.limit,
+		})
+		if err != nil {
+			t.Fatalf("SyncCollection(limit %v) = %v", tc.limit, err)
+		}
+		if resp.SyncToken != "http://example.com/ns/sync/2" {
+			t.Errorf("SyncToken = %q, expected %q", resp.SyncToken, "http://example.com/ns/sync/2")
+		}
+		if len(resp.Updated) != tc.updated || resp.Updated[0].Path != objects[0].Path || resp.Updated[0].ETag != objects[0].ETag {
+			t.Errorf("SyncCollection(limit %v) Updated = %+v, expected %v objects", tc.limit, resp.Updated, tc.updated)
+		}
+		if len(resp.Deleted) != 1 || resp.Deleted[0] != abPath+"deleted.vcf" {
+			t.Errorf("Deleted = %v, expected [%vdeleted.vcf]", resp.Deleted, abPath)
+		}
+		if resp.Truncated != tc.truncated {
+			t.Errorf("SyncCollection(limit %v) Truncated = %v, expected %v", tc.limit, resp.Truncated, tc.truncated)
+		}
+	}
+}
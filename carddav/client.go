@@ -3,6 +3,7 @@ package carddav
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
 	"fmt"
 	"mime"
 	"net/http"
@@ -76,6 +77,41 @@ func decodeSupportedAddressData(supported *supportedAddressData) []AddressDataTy
 	return l
 }
 
+// UpdateAddressBook changes the properties of the address book at path, and
+// returns the status of each property. Updates are atomic: if a property
+// can't be updated, none are and an error is returned along with the
+// statuses.
+func (c *Client) UpdateAddressBook(ctx context.Context, path string, update *AddressBookUpdate) ([]webdav.PropStatus, error) {
+	var values internal.PropertyUpdateValues
+	values.Add(update.Name, func(s string) interface{} {
+		return &internal.DisplayName{Name: s}
+	})
+	values.Add(update.Description, func(s string) interface{} {
+		return &addressbookDescription{Description: s}
+	})
+	if values.IsEmpty() {
+		return nil, nil
+	}
+
+	pu, err := internal.NewPropertyUpdate(values.Set, values.Remove)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.ic.PropPatch(ctx, path, pu)
+	if err != nil {
+		return nil, err
+	}
+	return decodePropStatuses(resp)
+}
+
+func decodePropStatuses(resp *internal.Response) ([]webdav.PropStatus, error) {
+	var l []webdav.PropStatus
+	err := internal.DecodePropertyUpdate(resp, "carddav", func(name xml.Name, code int, err error) {
+		l = append(l, webdav.PropStatus{Name: name, Code: code, Err: err})
+	})
+	return l, err
+}
+
 func (c *Client) FindAddressBooks(ctx context.Context, addressBookHomeSet string) ([]AddressBook, error) {
 	propfind := internal.NewPropNamePropFind(
 		internal.ResourceTypeName,
@@ -192,6 +228,7 @@ func encodeParamFilter(pf *ParamFilter) (*paramFilter, error) {
 func encodeTextMatch(tm *TextMatch) *textMatch {
 	return &textMatch{
 		Text:            tm.Text,
+		Collation:       string(tm.Collation),
 		NegateCondition: negateCondition(tm.NegateCondition),
 		MatchType:       matchType(tm.MatchType),
 	}
@@ -271,7 +308,7 @@ func (c *Client) QueryAddressBook(ctx context.Context, addressBook string, query
 
 	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, newPreconditionError(err)
 	}
 
 	return decodeAddressList(ms)
@@ -304,7 +341,7 @@ func (c *Client) MultiGetAddressBook(ctx context.Context, path string, multiGet
 
 	ms, err := c.ic.DoMultiStatus(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, newPreconditionError(err)
 	}
 
 	return decodeAddressList(ms)
@@ -379,6 +416,9 @@ func (c *Client) GetAddressObject(ctx context.Context, path string) (*AddressObj
 	return ao, nil
 }
 
+// PutAddressObject stores an address object at path. If the server rejects
+// the object, for instance because its UID is already used, a
+// *PreconditionError is returned.
 func (c *Client) PutAddressObject(ctx context.Context, path string, card vcard.Card) (*AddressObject, error) {
 	// TODO: add support for If-None-Match and If-Match
 
@@ -406,7 +446,7 @@ func (c *Client) PutAddressObject(ctx context.Context, path string, card vcard.C
 
 	resp, err := c.ic.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, newPreconditionError(err)
 	}
 	resp.Body.Close()
 
@@ -442,6 +482,9 @@ func (c *Client) SyncCollection(ctx context.Context, path string, query *SyncQue
 			if err, ok := err.(*internal.HTTPError); ok && err.Code == http.StatusNotFound {
 				ret.Deleted = append(ret.Deleted, p)
 				continue
+			} else if ok && err.Code == http.StatusInsufficientStorage && (p == path || path == fmt.Sprintf("%s/", p)) {
+				ret.Truncated = true
+				continue
 			}
 			return nil, err
 		}
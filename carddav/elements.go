@@ -194,6 +194,7 @@ type addressDataResp struct {
 type reportReq struct {
 	Query    *addressbookQuery
 	Multiget *addressbookMultiget
+	Sync     *internal.SyncCollectionQuery
 }
 
 func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -205,6 +206,9 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	case addressBookMultigetName:
 		r.Multiget = &addressbookMultiget{}
 		v = r.Multiget
+	case internal.SyncCollectionName:
+		r.Sync = &internal.SyncCollectionQuery{}
+		v = r.Sync
 	default:
 		return fmt.Errorf("carddav: unsupported REPORT root %q %q", start.Name.Space, start.Name.Local)
 	}
@@ -219,3 +223,9 @@ type mkcolReq struct {
 	Description  addressbookDescription `xml:"set>prop>addressbook-description"`
 	// TODO this could theoretically contain all addressbook properties?
 }
+
+// https://tools.ietf.org/html/rfc6352#section-6.3.2.1
+type noUIDConflict struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:carddav no-uid-conflict"`
+	Href    internal.Href `xml:"DAV: href"`
+}
@@ -0,0 +1,365 @@
+package carddav
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/emersion/go-vcard"
+	"github.com/trvita/caldav-client-yandex/internal"
+)
+
+// localAddressBookMetadataFile is the name of the sidecar file holding
+// address book properties which can't be stored in the address objects
+// themselves.
+const localAddressBookMetadataFile = ".addressbook.json"
+
+// LocalBackend is a Backend storing address books in a local directory. Each
+// address book is a sub-directory containing one .vcf file per address
+// object, along with a metadata sidecar file.
+type LocalBackend struct {
+	dir           string
+	principalPath string
+	homeSetPath   string
+
+	// mu serializes writes, so that conditional requests and UID checks
+	// aren't racy within a single process
+	mu sync.RWMutex
+}
+
+type localAddressBookMetadata struct {
+	Name                 string            `json:"name,omitempty"`
+	Description          string            `json:"description,omitempty"`
+	MaxResourceSize      int64             `json:"maxResourceSize,omitempty"`
+	SupportedAddressData []AddressDataType `json:"supportedAddressData,omitempty"`
+}
+
+var _ Backend = (*LocalBackend)(nil)
+
+// NewLocalBackend creates a new LocalBackend storing address books in dir,
+// and serving them for the principal at principalPath, below homeSetPath.
+func NewLocalBackend(dir, principalPath, homeSetPath string) *LocalBackend {
+	return &LocalBackend{
+		dir:           dir,
+		principalPath: principalPath,
+		homeSetPath:   homeSetPath,
+	}
+}
+
+// addressBookDir returns the name and local directory of the address book
+// at p.
+func (b *LocalBackend) addressBookDir(p string) (name, dir string, err error) {
+	p = internal.CleanPath(p)
+	name = path.Base(p)
+	if path.Dir(p) != internal.CleanPath(b.homeSetPath) || !internal.IsValidLocalName(name) {
+		return "", "", internal.HTTPErrorf(http.StatusNotFound, "carddav: no address book at %q", p)
+	}
+	return name, filepath.Join(b.dir, name), nil
+}
+
+// objectFile returns the path and local directory of the address book
+// containing the address object at p, and the object's file name.
+func (b *LocalBackend) objectFile(p string) (abPath, dir, file string, err error) {
+	p = internal.CleanPath(p)
+	name, dir, err := b.addressBookDir(path.Dir(p))
+	if err != nil {
+		return "", "", "", err
+	}
+	file = path.Base(p)
+	if !internal.IsValidLocalName(file) || path.Ext(file) != ".vcf" {
+		return "", "", "", internal.HTTPErrorf(http.StatusNotFound, "carddav: no address object at %q", p)
+	}
+	return b.addressBookPath(name), dir, file, nil
+}
+
+func (b *LocalBackend) addressBookPath(name string) string {
+	return path.Join(internal.CleanPath(b.homeSetPath), name) + "/"
+}
+
+func (b *LocalBackend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	return b.principalPath, nil
+}
+
+func (b *LocalBackend) AddressBookHomeSetPath(ctx context.Context) (string, error) {
+	return b.homeSetPath, nil
+}
+
+func (b *LocalBackend) readAddressBook(name, dir string) (*AddressBook, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	} else if !fi.IsDir() {
+		return nil, internal.HTTPErrorf(http.StatusNotFound, "carddav: no address book at %q", b.addressBookPath(name))
+	}
+
+	var md localAddressBookMetadata
+	data, err := os.ReadFile(filepath.Join(dir, localAddressBookMetadataFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &md); err != nil {
+			return nil, fmt.Errorf("carddav: failed to read metadata of address book %q: %v", name, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, internal.ErrFromOS(err)
+	}
+
+	return &AddressBook{
+		Path:                 b.addressBookPath(name),
+		Name:                 md.Name,
+		Description:          md.Description,
+		MaxResourceSize:      md.MaxResourceSize,
+		SupportedAddressData: md.SupportedAddressData,
+	}, nil
+}
+
+func writeAddressBookMetadata(dir string, ab *AddressBook) error {
+	data, err := json.MarshalIndent(&localAddressBookMetadata{
+		Name:                 ab.Name,
+		Description:          ab.Description,
+		MaxResourceSize:      ab.MaxResourceSize,
+		SupportedAddressData: ab.SupportedAddressData,
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return internal.WriteFileAtomic(dir, localAddressBookMetadataFile, data)
+}
+
+func (b *LocalBackend) CreateAddressBook(ctx context.Context, addressBook *AddressBook) error {
+	name, dir, err := b.addressBookDir(addressBook.Path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return internal.ErrFromOS(err)
+	}
+	if err := os.Mkdir(dir, 0755); os.IsExist(err) {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "carddav: address book %q already exists", b.addressBookPath(name))
+	} else if err != nil {
+		return internal.ErrFromOS(err)
+	}
+	if err := writeAddressBookMetadata(dir, addressBook); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+func (b *LocalBackend) ListAddressBooks(ctx context.Context) ([]AddressBook, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	entries, err := os.ReadDir(b.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+
+	var l []AddressBook
+	for _, entry := range entries {
+		if !entry.IsDir() || !internal.IsValidLocalName(entry.Name()) {
+			continue
+		}
+		ab, err := b.readAddressBook(entry.Name(), filepath.Join(b.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, *ab)
+	}
+	return l, nil
+}
+
+func (b *LocalBackend) GetAddressBook(ctx context.Context, path string) (*AddressBook, error) {
+	name, dir, err := b.addressBookDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.readAddressBook(name, dir)
+}
+
+func (b *LocalBackend) DeleteAddressBook(ctx context.Context, path string) error {
+	name, dir, err := b.addressBookDir(path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.readAddressBook(name, dir); err != nil {
+		return err
+	}
+	return internal.ErrFromOS(os.RemoveAll(dir))
+}
+
+func readAddressObject(p, filename string) (*AddressObject, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+	card, err := vcard.NewDecoder(f).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("carddav: failed to decode address object %q: %v", p, err)
+	}
+
+	return &AddressObject{
+		Path:          p,
+		ModTime:       fi.ModTime().UTC(),
+		ContentLength: fi.Size(),
+		ETag:          internal.FileETag(fi),
+		Card:          card,
+	}, nil
+}
+
+func (b *LocalBackend) GetAddressObject(ctx context.Context, path string, req *AddressDataRequest) (*AddressObject, error) {
+	_, dir, file, err := b.objectFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return readAddressObject(internal.CleanPath(path), filepath.Join(dir, file))
+}
+
+// listAddressObjects returns all address objects in the address book at p.
+// The caller must hold b.mu.
+func (b *LocalBackend) listAddressObjects(p string) ([]AddressObject, error) {
+	name, dir, err := b.addressBookDir(p)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, internal.ErrFromOS(err)
+	}
+
+	abPath := b.addressBookPath(name)
+	var l []AddressObject
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !internal.IsValidLocalName(entry.Name()) || filepath.Ext(entry.Name()) != ".vcf" {
+			continue
+		}
+		ao, err := readAddressObject(abPath+entry.Name(), filepath.Join(dir, entry.Name()))
+		if _, ok := err.(*internal.HTTPError); ok {
+			return nil, err
+		} else if err != nil {
+			// Skip objects which can't be decoded, so that a single bad
+			// file doesn't make the whole address book unavailable
+			continue
+		}
+		l = append(l, *ao)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Path < l[j].Path
+	})
+	return l, nil
+}
+
+func (b *LocalBackend) ListAddressObjects(ctx context.Context, path string, req *AddressDataRequest) ([]AddressObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.listAddressObjects(path)
+}
+
+func (b *LocalBackend) QueryAddressObjects(ctx context.Context, path string, query *AddressBookQuery) ([]AddressObject, error) {
+	aos, err := b.ListAddressObjects(ctx, path, &query.DataRequest)
+	if err != nil {
+		return nil, err
+	}
+	return Filter(query, aos)
+}
+
+// checkConditionalMatch checks the If-Match and If-None-Match conditions in
+// opts against the ETag of the existing address object, which is empty if
+// the object doesn't exist.
+func checkConditionalMatch(opts *PutAddressObjectOptions, etag string) error {
+	if opts == nil {
+		return nil
+	}
+	return internal.CheckConditionalMatch(string(opts.IfNoneMatch), string(opts.IfMatch), etag)
+}
+
+func (b *LocalBackend) PutAddressObject(ctx context.Context, path string, card vcard.Card, opts *PutAddressObjectOptions) (*AddressObject, bool, error) {
+	abPath, dir, file, err := b.objectFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var buf bytes.Buffer
+	if err := vcard.NewEncoder(&buf).Encode(card); err != nil {
+		return nil, false, NewPreconditionError(PreconditionValidAddressData)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	aos, err := b.listAddressObjects(abPath)
+	if httpErr, ok := err.(*internal.HTTPError); ok && httpErr.Code == http.StatusNotFound {
+		return nil, false, internal.HTTPErrorf(http.StatusConflict, "carddav: no address book at %q", abPath)
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	// Objects which can't be decoded aren't listed, check the file itself
+	etag, err := internal.StatETag(filepath.Join(dir, file))
+	if err != nil {
+		return nil, false, err
+	}
+	created := etag == ""
+
+	p := abPath + file
+	uid := card.Value(vcard.FieldUID)
+	for _, ao := range aos {
+		if ao.Path == p {
+			continue
+		} else if uid != "" && ao.Card.Value(vcard.FieldUID) == uid {
+			return nil, false, NewUIDConflictError(ao.Path)
+		}
+	}
+	if err := checkConditionalMatch(opts, etag); err != nil {
+		return nil, false, err
+	}
+
+	if err := internal.WriteFileAtomic(dir, file, buf.Bytes()); err != nil {
+		return nil, false, err
+	}
+	ao, err := readAddressObject(p, filepath.Join(dir, file))
+	return ao, created, err
+}
+
+func (b *LocalBackend) DeleteAddressObject(ctx context.Context, path string) error {
+	_, dir, file, err := b.objectFile(path)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return internal.ErrFromOS(os.Remove(filepath.Join(dir, file)))
+}
@@ -0,0 +1,130 @@
+package carddav
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	webdav "github.com/trvita/caldav-client-yandex"
+	"github.com/trvita/caldav-client-yandex/internal"
+)
+
+func httpErrorCode(err error) int {
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return 0
+}
+
+func newTestCard(uid, name string) vcard.Card {
+	card := make(vcard.Card)
+	card.SetValue(vcard.FieldVersion, "3.0")
+	card.SetValue(vcard.FieldUID, uid)
+	card.SetValue(vcard.FieldFormattedName, name)
+	return card
+}
+
+func TestLocalBackend(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	b := NewLocalBackend(dir, "/user/", "/user/contacts/")
+
+	if err := b.CreateAddressBook(ctx, &AddressBook{Path: "/user/contacts/default", Name: "Contacts"}); err != nil {
+		t.Fatalf("CreateAddressBook() = %v", err)
+	}
+	if err := b.CreateAddressBook(ctx, &AddressBook{Path: "/user/contacts/default/"}); httpErrorCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("CreateAddressBook() on existing address book = %v, expected 405", err)
+	}
+
+	abs, err := NewLocalBackend(dir, "/user/", "/user/contacts/").ListAddressBooks(ctx)
+	if err != nil {
+		t.Fatalf("ListAddressBooks() = %v", err)
+	}
+	if len(abs) != 1 || abs[0].Path != "/user/contacts/default/" || abs[0].Name != "Contacts" {
+		t.Errorf("ListAddressBooks() = %+v", abs)
+	}
+
+	ao, created, err := b.PutAddressObject(ctx, "/user/contacts/default/alice.vcf", newTestCard("alice", "Alice"), &PutAddressObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch("*"),
+	})
+	if err != nil {
+		t.Fatalf("PutAddressObject() = %v", err)
+	}
+	if !created {
+		t.Errorf("PutAddressObject() of new object reported an update")
+	}
+	if _, created, err := b.PutAddressObject(ctx, ao.Path, newTestCard("alice", "Alice"), nil); err != nil || created {
+		t.Errorf("PutAddressObject() of existing object = %v, created %v", err, created)
+	}
+	_, _, err = b.PutAddressObject(ctx, ao.Path, newTestCard("alice", "Alice Bis"), &PutAddressObjectOptions{
+		IfMatch: webdav.ConditionalMatch(`"outdated"`),
+	})
+	if httpErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("PutAddressObject() with stale If-Match = %v, expected 412", err)
+	}
+	_, _, err = b.PutAddressObject(ctx, "/user/contacts/default/other.vcf", newTestCard("alice", "Duplicate"), nil)
+	if httpErrorCode(err) != http.StatusConflict {
+		t.Errorf("PutAddressObject() with duplicate UID = %v, expected 409", err)
+	}
+
+	// The conflicting address object is reported to clients
+	ts := httptest.NewServer(&Handler{Backend: b})
+	defer ts.Close()
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	_, err = client.PutAddressObject(ctx, "/user/contacts/default/other.vcf", newTestCard("alice", "Duplicate"))
+	var precondErr *PreconditionError
+	if !errors.As(err, &precondErr) || precondErr.Precondition != PreconditionNoUIDConflict || precondErr.Href != ao.Path {
+		t.Errorf("Client.PutAddressObject() with duplicate UID = %v, expected a no-uid-conflict error with href %q", err, ao.Path)
+	}
+
+	got, err := b.GetAddressObject(ctx, ao.Path, &AddressDataRequest{})
+	if err != nil {
+		t.Fatalf("GetAddressObject() = %v", err)
+	}
+	if got.ETag != ao.ETag || got.Card.PreferredValue(vcard.FieldFormattedName) != "Alice" {
+		t.Errorf("GetAddressObject() = %+v, expected %+v", got, ao)
+	}
+
+	// A file which can't be decoded must not break listings
+	if err := os.WriteFile(filepath.Join(dir, "default", "broken.vcf"), []byte("BEGIN:VCARD\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	aos, err := b.ListAddressObjects(ctx, "/user/contacts/default/", &AddressDataRequest{})
+	if err != nil {
+		t.Fatalf("ListAddressObjects() with a broken file = %v", err)
+	}
+	if len(aos) != 1 || aos[0].Path != ao.Path {
+		t.Errorf("ListAddressObjects() with a broken file = %+v, expected only %v", aos, ao.Path)
+	}
+
+	// ... but it still exists when overwritten
+	broken := "/user/contacts/default/broken.vcf"
+	_, _, err = b.PutAddressObject(ctx, broken, newTestCard("bob", "Bob"), &PutAddressObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch("*"),
+	})
+	if httpErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("PutAddressObject() with If-None-Match on a broken file = %v, expected 412", err)
+	}
+	_, created, err = b.PutAddressObject(ctx, broken, newTestCard("bob", "Bob"), &PutAddressObjectOptions{
+		IfMatch: webdav.ConditionalMatch("*"),
+	})
+	if err != nil || created {
+		t.Errorf("PutAddressObject() with If-Match on a broken file = %v, created %v", err, created)
+	}
+
+	if err := b.DeleteAddressBook(ctx, "/user/contacts/default/"); err != nil {
+		t.Fatalf("DeleteAddressBook() = %v", err)
+	}
+	if _, err := b.GetAddressObject(ctx, ao.Path, &AddressDataRequest{}); httpErrorCode(err) != http.StatusNotFound {
+		t.Errorf("GetAddressObject() in deleted address book = %v, expected 404", err)
+	}
+}
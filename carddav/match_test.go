@@ -260,6 +260,13 @@ FN;PID=1.1:Alice Gopher
 N:Gopher;Alice;;;
 EMAIL;PID=1.1:alice@example.com
 CLIENTPIDMAP:1;urn:uuid:53e374d9-337e-4727-8803-a1e9c14e0556
+END:VCARD`)
+
+	ivan := newAO(`BEGIN:VCARD
+VERSION:4.0
+UID:urn:uuid:4fbe8971-0bc3-424c-9c26-36c3e1eff6b7
+FN:Иван Иванов
+N:Ivanov;Ivan;;;
 END:VCARD`)
 
 	for _, tc := range []struct {
@@ -590,6 +597,92 @@ END:VCARD`)
 			addr: alice,
 			want: true,
 		},
+		{
+			name: "match-name-default-collation-ok",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldName,
+						TextMatches: []TextMatch{{Text: "ivanov"}},
+					},
+				},
+			},
+			addr: ivan,
+			want: true,
+		},
+		{
+			name: "match-name-octet-not",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldName,
+						TextMatches: []TextMatch{{Text: "ivanov", Collation: CollationOctet}},
+					},
+				},
+			},
+			addr: ivan,
+			want: false,
+		},
+		{
+			name: "match-name-ascii-casemap-equals-ok",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name: vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{
+							Text:      "ALICE GOPHER",
+							MatchType: MatchEquals,
+							Collation: CollationASCIICasemap,
+						}},
+					},
+				},
+			},
+			addr: alice,
+			want: true,
+		},
+		{
+			name: "match-name-ascii-casemap-non-ascii-not",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{Text: "иванов", Collation: CollationASCIICasemap}},
+					},
+				},
+			},
+			addr: ivan,
+			want: false,
+		},
+		{
+			name: "match-name-unicode-casemap-starts-with-ok",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name: vcard.FieldFormattedName,
+						TextMatches: []TextMatch{{
+							Text:      "иван и",
+							MatchType: MatchStartsWith,
+							Collation: CollationUnicodeCasemap,
+						}},
+					},
+				},
+			},
+			addr: ivan,
+			want: true,
+		},
+		{
+			name: "unsupported-collation",
+			query: &AddressBookQuery{
+				PropFilters: []PropFilter{
+					{
+						Name:        vcard.FieldName,
+						TextMatches: []TextMatch{{Text: "ivanov", Collation: "i;basic"}},
+					},
+				},
+			},
+			addr: ivan,
+			err:  fmt.Errorf("webdav: unsupported collation \"i;basic\""),
+		},
 		{
 			name: "invalid-query-filter",
 			query: &AddressBookQuery{
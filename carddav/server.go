@@ -35,12 +35,28 @@ type Backend interface {
 	GetAddressObject(ctx context.Context, path string, req *AddressDataRequest) (*AddressObject, error)
 	ListAddressObjects(ctx context.Context, path string, req *AddressDataRequest) ([]AddressObject, error)
 	QueryAddressObjects(ctx context.Context, path string, query *AddressBookQuery) ([]AddressObject, error)
-	PutAddressObject(ctx context.Context, path string, card vcard.Card, opts *PutAddressObjectOptions) (*AddressObject, error)
+	// PutAddressObject stores an address object resource and reports whether
+	// it was created, rather than updated.
+	PutAddressObject(ctx context.Context, path string, card vcard.Card, opts *PutAddressObjectOptions) (ao *AddressObject, created bool, err error)
 	DeleteAddressObject(ctx context.Context, path string) error
 
 	webdav.UserPrincipalBackend
 }
 
+// SyncBackend is an optional interface a Backend can implement to support
+// the sync-collection REPORT, as defined in RFC 6578.
+type SyncBackend interface {
+	// AddressBookSyncToken returns the current sync token of an address book.
+	AddressBookSyncToken(ctx context.Context, path string) (string, error)
+	// SyncAddressObjects returns the address objects changed and deleted
+	// since query.SyncToken, along with the new sync token. An empty
+	// query.SyncToken requests an initial synchronization. If query.Limit is
+	// positive, at most query.Limit changes must be returned and Truncated
+	// must be set if more are pending. An invalid or expired query.SyncToken
+	// must be reported with NewInvalidSyncTokenError.
+	SyncAddressObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error)
+}
+
 // Handler handles CardDAV HTTP requests. It can be used to create a CardDAV
 // server.
 type Handler struct {
@@ -94,8 +110,10 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 		return h.handleQuery(r, w, report.Query)
 	} else if report.Multiget != nil {
 		return h.handleMultiget(r.Context(), w, report.Multiget)
+	} else if report.Sync != nil {
+		return h.handleSyncCollection(r, w, report.Sync)
 	}
-	return internal.HTTPErrorf(http.StatusBadRequest, "carddav: expected addressbook-query or addressbook-multiget element in REPORT request")
+	return internal.HTTPErrorf(http.StatusBadRequest, "carddav: expected addressbook-query, addressbook-multiget or sync-collection element in REPORT request")
 }
 
 func decodePropFilter(el *propFilter) (*PropFilter, error) {
@@ -106,8 +124,12 @@ func decodePropFilter(el *propFilter) (*PropFilter, error) {
 		}
 		pf.IsNotDefined = true
 	}
-	for _, tm := range el.TextMatches {
-		pf.TextMatches = append(pf.TextMatches, *decodeTextMatch(&tm))
+	for _, tmEl := range el.TextMatches {
+		tm, err := decodeTextMatch(&tmEl)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatches = append(pf.TextMatches, *tm)
 	}
 	for _, paramEl := range el.Params {
 		param, err := decodeParamFilter(&paramEl)
@@ -128,17 +150,25 @@ func decodeParamFilter(el *paramFilter) (*ParamFilter, error) {
 		pf.IsNotDefined = true
 	}
 	if el.TextMatch != nil {
-		pf.TextMatch = decodeTextMatch(el.TextMatch)
+		tm, err := decodeTextMatch(el.TextMatch)
+		if err != nil {
+			return nil, err
+		}
+		pf.TextMatch = tm
 	}
 	return pf, nil
 }
 
-func decodeTextMatch(tm *textMatch) *TextMatch {
+func decodeTextMatch(tm *textMatch) (*TextMatch, error) {
+	if tm.Collation != "" && !internal.IsSupportedCollation(tm.Collation) {
+		return nil, NewPreconditionError(PreconditionSupportedCollation)
+	}
 	return &TextMatch{
 		Text:            tm.Text,
 		NegateCondition: bool(tm.NegateCondition),
 		MatchType:       MatchType(tm.MatchType),
-	}
+		Collation:       Collation(tm.Collation),
+	}, nil
 }
 
 func decodeAddressDataReq(addressData *addressDataReq) (*AddressDataRequest, error) {
@@ -251,6 +281,61 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 	return internal.ServeMultiStatus(w, ms)
 }
 
+func (h *Handler) handleSyncCollection(r *http.Request, w http.ResponseWriter, sync *internal.SyncCollectionQuery) error {
+	syncBackend, ok := h.Backend.(SyncBackend)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusNotImplemented, "carddav: sync-collection not supported")
+	}
+
+	switch sync.SyncLevel {
+	case "1", "infinite":
+		// address books don't contain nested collections
+	default:
+		return internal.HTTPErrorf(http.StatusBadRequest, "carddav: invalid sync-level %q", sync.SyncLevel)
+	}
+
+	q := SyncQuery{SyncToken: sync.SyncToken}
+	if sync.Prop != nil {
+		var addressData addressDataReq
+		if err := sync.Prop.Decode(&addressData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		req, err := decodeAddressDataReq(&addressData)
+		if err != nil {
+			return err
+		}
+		q.DataRequest = *req
+	}
+	if sync.Limit != nil {
+		q.Limit = int(sync.Limit.NResults)
+	}
+
+	sr, err := syncBackend.SyncAddressObjects(r.Context(), r.URL.Path, &q)
+	if err != nil {
+		return err
+	}
+
+	b := backend{
+		Backend: h.Backend,
+		Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+	}
+	propfind := internal.PropFind{Prop: sync.Prop}
+	if propfind.Prop == nil {
+		propfind.Prop = &internal.Prop{}
+	}
+
+	var resps []internal.Response
+	for _, ao := range sr.Updated {
+		resp, err := b.propFindAddressObject(r.Context(), &propfind, &ao)
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+	}
+
+	return internal.ServeSyncCollection(w, r.URL.Path, resps, sr.Deleted, sr.Truncated, sr.SyncToken)
+}
+
 type backend struct {
 	Backend Backend
 	Prefix  string
@@ -524,6 +609,15 @@ func (b *backend) propFindAddressBook(ctx context.Context, propfind *internal.Pr
 			return &maxResourceSize{Size: ab.MaxResourceSize}, nil
 		}
 	}
+	if syncBackend, ok := b.Backend.(SyncBackend); ok {
+		props[internal.SyncTokenName] = func(*internal.RawXMLValue) (interface{}, error) {
+			token, err := syncBackend.AddressBookSyncToken(ctx, ab.Path)
+			if err != nil {
+				return nil, err
+			}
+			return &internal.SyncToken{Token: token}, nil
+		}
+	}
 
 	return internal.NewPropFindResponse(ab.Path, propfind, props)
 }
@@ -678,8 +772,7 @@ func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
 		return internal.HTTPErrorf(http.StatusBadRequest, "carddav: failed to parse vCard: %v", err)
 	}
 
-	// TODO: add support for the CARDDAV:no-uid-conflict error
-	ao, err := b.Backend.PutAddressObject(r.Context(), r.URL.Path, card, &opts)
+	ao, created, err := b.Backend.PutAddressObject(r.Context(), r.URL.Path, card, &opts)
 	if err != nil {
 		return err
 	}
@@ -689,12 +782,15 @@ func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
 	if !ao.ModTime.IsZero() {
 		w.Header().Set("Last-Modified", ao.ModTime.UTC().Format(http.TimeFormat))
 	}
-	if ao.Path != "" {
-		w.Header().Set("Location", ao.Path)
-	}
 
-	// TODO: http.StatusNoContent if the resource already existed
-	w.WriteHeader(http.StatusCreated)
+	if created {
+		if ao.Path != "" {
+			w.Header().Set("Location", ao.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
 
 	return nil
 }
@@ -751,15 +847,27 @@ const (
 	PreconditionSupportedAddressData PreconditionType = "supported-address-data"
 	PreconditionValidAddressData     PreconditionType = "valid-address-data"
 	PreconditionMaxResourceSize      PreconditionType = "max-resource-size"
+	PreconditionSupportedCollation   PreconditionType = "supported-collation"
 )
 
 func NewPreconditionError(err PreconditionType) error {
 	name := xml.Name{Space: "urn:ietf:params:xml:ns:carddav", Local: string(err)}
-	elem := internal.NewRawXMLElement(name, nil, nil)
-	return &internal.HTTPError{
-		Code: 409,
-		Err: &internal.Error{
-			Raw: []internal.RawXMLValue{*elem},
-		},
+	return internal.NewPreconditionError(internal.NewRawXMLElement(name, nil, nil))
+}
+
+// NewInvalidSyncTokenError returns the error SyncAddressObjects must return when
+// the sync token is invalid or has expired, asking the client to restart
+// with an initial synchronization.
+func NewInvalidSyncTokenError() error {
+	return internal.NewInvalidSyncTokenError()
+}
+
+// NewUIDConflictError returns a no-uid-conflict precondition error, reporting
+// the path of the address object resource already using the UID.
+func NewUIDConflictError(href string) error {
+	elem, err := internal.EncodeRawXMLElement(&noUIDConflict{Href: internal.Href{Path: href}})
+	if err != nil {
+		return err
 	}
+	return internal.NewPreconditionError(elem)
 }
@@ -35,6 +35,86 @@ func HTTPClientWithBasicAuth(c HTTPClient, username, password string) HTTPClient
 	return &basicAuthHTTPClient{c, username, password}
 }
 
+// TokenSource provides access tokens for HTTPClientWithToken.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenRefresher is an optional interface a TokenSource can implement to
+// obtain a new access token when the server rejects the current one.
+type TokenRefresher interface {
+	// RefreshToken returns a new access token to replace rejected.
+	RefreshToken(ctx context.Context, rejected string) (string, error)
+}
+
+// StaticToken is a TokenSource always returning the same access token.
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+type tokenHTTPClient struct {
+	c      HTTPClient
+	scheme string
+	ts     TokenSource
+}
+
+func (c *tokenHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	token, err := c.ts.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("webdav: failed to get access token: %v", err)
+	}
+	// Clients must not modify the request, see http.RoundTripper
+	authReq := req.Clone(ctx)
+	authReq.Header.Set("Authorization", c.scheme+" "+token)
+
+	resp, err := c.c.Do(authReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The request can only be retried if its body can be sent again
+	refresher, ok := c.ts.(TokenRefresher)
+	if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return resp, nil
+	}
+	retry := req.Clone(ctx)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+
+	token, err = refresher.RefreshToken(ctx, token)
+	if err != nil {
+		if retry.Body != nil {
+			retry.Body.Close()
+		}
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry.Header.Set("Authorization", c.scheme+" "+token)
+	return c.c.Do(retry)
+}
+
+// HTTPClientWithToken returns an HTTP client that adds an access token to the
+// Authorization header of all outgoing requests, using the provided scheme
+// (e.g. "Bearer", or "OAuth" for Yandex). If c is nil, http.DefaultClient is
+// used.
+//
+// If the server replies with 401 Unauthorized and ts implements
+// TokenRefresher, the token is refreshed and the request is retried once.
+func HTTPClientWithToken(c HTTPClient, scheme string, ts TokenSource) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &tokenHTTPClient{c, scheme, ts}
+}
+
 // Client provides access to a remote WebDAV filesystem.
 type Client struct {
 	ic *internal.Client
@@ -44,7 +124,8 @@ type Client struct {
 //
 // If the HTTPClient is nil, http.DefaultClient is used.
 //
-// To use HTTP basic authentication, HTTPClientWithBasicAuth can be used.
+// To use HTTP basic authentication, HTTPClientWithBasicAuth can be used. For
+// OAuth or bearer tokens, HTTPClientWithToken can be used.
 func NewClient(c HTTPClient, endpoint string) (*Client, error) {
 	ic, err := internal.NewClient(c, endpoint)
 	if err != nil {
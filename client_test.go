@@ -0,0 +1,161 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testTokenSource hands out "old" until refreshed, then "new".
+type testTokenSource struct {
+	token      string
+	refreshErr error
+}
+
+func (ts *testTokenSource) Token(ctx context.Context) (string, error) {
+	return ts.token, nil
+}
+
+func (ts *testTokenSource) RefreshToken(ctx context.Context, rejected string) (string, error) {
+	if ts.refreshErr != nil {
+		return "", ts.refreshErr
+	}
+	if rejected != ts.token {
+		return "", errors.New("unexpected rejected token")
+	}
+	ts.token = "new"
+	return ts.token, nil
+}
+
+type tokenRequest struct {
+	auth, body string
+}
+
+// newTokenServer returns a server only accepting the "new" token, recording
+// the Authorization header and body of each request.
+func newTokenServer(t *testing.T) (*httptest.Server, *[]tokenRequest) {
+	var reqs []tokenRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, tokenRequest{r.Header.Get("Authorization"), string(body)})
+		if r.Header.Get("Authorization") != "OAuth new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &reqs
+}
+
+func TestHTTPClientWithToken(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		ts         TokenSource
+		body       func() io.Reader
+		refreshErr error
+		code       int
+		requests   []tokenRequest
+	}{
+		{
+			name:     "refresh without body",
+			code:     http.StatusNoContent,
+			requests: []tokenRequest{{"OAuth old", ""}, {"OAuth new", ""}},
+		},
+		{
+			name: "refresh with replayable body",
+			body: func() io.Reader {
+				// Sets Request.GetBody
+				return strings.NewReader("data")
+			},
+			code:     http.StatusNoContent,
+			requests: []tokenRequest{{"OAuth old", "data"}, {"OAuth new", "data"}},
+		},
+		{
+			name: "body can't be replayed",
+			body: func() io.Reader {
+				return io.MultiReader(strings.NewReader("data"))
+			},
+			code:     http.StatusUnauthorized,
+			requests: []tokenRequest{{"OAuth old", "data"}},
+		},
+		{
+			name:       "refresh error",
+			refreshErr: errors.New("refresh token expired"),
+			code:       http.StatusUnauthorized,
+			requests:   []tokenRequest{{"OAuth old", ""}},
+		},
+		{
+			name:     "no refresher",
+			ts:       StaticToken("old"),
+			code:     http.StatusUnauthorized,
+			requests: []tokenRequest{{"OAuth old", ""}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, reqs := newTokenServer(t)
+			tokenSource := &testTokenSource{token: "old", refreshErr: tc.refreshErr}
+			ts := tc.ts
+			if ts == nil {
+				ts = tokenSource
+			}
+			c := HTTPClientWithToken(nil, "OAuth", ts)
+
+			var body io.Reader
+			if tc.body != nil {
+				body = tc.body()
+			}
+			req, err := http.NewRequest(http.MethodPut, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do() = %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.code {
+				t.Errorf("Do() status = %v, want %v", resp.StatusCode, tc.code)
+			}
+			if len(*reqs) != len(tc.requests) {
+				t.Fatalf("server got %+v, want %+v", *reqs, tc.requests)
+			}
+			for i, got := range *reqs {
+				if got != tc.requests[i] {
+					t.Errorf("server request #%v = %+v, want %+v", i, got, tc.requests[i])
+				}
+			}
+			if auth := req.Header.Get("Authorization"); auth != "" {
+				t.Errorf("Do() modified the request, Authorization = %q", auth)
+			}
+		})
+	}
+}
+
+type failingTokenSource struct{}
+
+func (failingTokenSource) Token(ctx context.Context) (string, error) {
+	return "", errors.New("not logged in")
+}
+
+func TestHTTPClientWithTokenError(t *testing.T) {
+	server, reqs := newTokenServer(t)
+	c := HTTPClientWithToken(nil, "Bearer", failingTokenSource{})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := c.Do(req); err == nil {
+		resp.Body.Close()
+		t.Errorf("Do() without token succeeded")
+	}
+	if len(*reqs) != 0 {
+		t.Errorf("server got %+v, want no requests", *reqs)
+	}
+}
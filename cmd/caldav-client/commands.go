@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/trvita/caldav-client-yandex/caldav"
+	"github.com/trvita/caldav-client-yandex/mycal"
+	"github.com/trvita/go-ical"
+)
+
+type session struct {
+	ctx     context.Context
+	client  *caldav.Client
+	homeset string
+	// out receives the command output
+	out io.Writer
+}
+
+func login(opts *options) (*session, error) {
+	credOpts := &mycal.CredentialOptions{
+		Profile:    opts.profile,
+		ConfigPath: opts.config,
+		NetrcPath:  opts.netrc,
+	}
+	if !opts.urlSet {
+		if p, err := credOpts.SelectedProfile(); err != nil {
+			return nil, fmt.Errorf("failed to look up profile: %v", err)
+		} else if p != nil && p.URL != "" {
+			opts.url = p.URL
+		}
+	}
+
+	creds, err := mycal.FindCredentials(opts.url, credOpts)
+	if err != nil && !errors.Is(err, mycal.ErrNoCredentials) {
+		return nil, fmt.Errorf("failed to look up credentials: %v", err)
+	}
+	if opts.user != "" && (creds == nil || creds.Username != opts.user) {
+		creds = &mycal.Credentials{Username: opts.user}
+	}
+
+	var (
+		client    *caldav.Client
+		principal string
+		ctx       context.Context
+	)
+	if creds != nil {
+		if creds.Password == "" {
+			creds.Password, err = mycal.GetPassword(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read password: %v", err)
+			}
+		}
+		client, principal, ctx, err = mycal.CreateClientWithCredentials(opts.url, creds.Username, creds.Password)
+	} else {
+		client, principal, ctx, err = mycal.CreateClient(opts.url, os.Stdin)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in: %v", err)
+	}
+
+	homeset, err := client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find calendar home set: %v", err)
+	}
+	return &session{ctx: ctx, client: client, homeset: homeset, out: os.Stdout}, nil
+}
+
+func runCommand(opts *options, group, action string, args []string) error {
+	var cmd func(*options, *session, []string) error
+	switch group + " " + action {
+	case "calendars list":
+		cmd = listCalendars
+	case "calendars create":
+		cmd = createCalendar
+	case "calendars delete":
+		cmd = deleteCalendar
+	case "calendars rename":
+		cmd = renameCalendar
+	case "calendars recolor":
+		cmd = recolorCalendar
+	case "events list":
+		cmd = listEvents
+	case "events add":
+		cmd = addEvent
+	case "events show":
+		cmd = showEvent
+	case "events delete":
+		cmd = deleteEvent
+	default:
+		return fmt.Errorf("unknown command %q: %w", group+" "+action, errUsage)
+	}
+
+	if group == "events" && opts.calendar == "" {
+		return fmt.Errorf("missing --calendar: %w", errUsage)
+	}
+
+	s, err := login(opts)
+	if err != nil {
+		return err
+	}
+	return cmd(opts, s, args)
+}
+
+func listCalendars(opts *options, s *session, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	calendars, err := mycal.ListCalendars(s.ctx, s.client, s.homeset)
+	if err != nil {
+		return err
+	}
+	return writeCalendars(s.out, opts.format, calendars)
+}
+
+func createCalendar(opts *options, s *session, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return mycal.CreateCalendar(s.ctx, s.client, s.homeset, args[0], opts.description, opts.color)
+}
+
+func deleteCalendar(opts *options, s *session, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	calendar, err := mycal.FindCalendar(s.ctx, s.client, s.homeset, args[0])
+	if err != nil {
+		return err
+	}
+	return mycal.Delete(s.ctx, s.client, calendar.Path)
+}
+
+func renameCalendar(opts *options, s *session, args []string) error {
+	if len(args) != 2 || args[1] == "" {
+		return errUsage
+	}
+	calendar, err := mycal.FindCalendar(s.ctx, s.client, s.homeset, args[0])
+	if err != nil {
+		return err
+	}
+	return mycal.RenameCalendar(s.ctx, s.client, calendar.Path, args[1])
+}
+
+func recolorCalendar(opts *options, s *session, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	calendar, err := mycal.FindCalendar(s.ctx, s.client, s.homeset, args[0])
+	if err != nil {
+		return err
+	}
+	return mycal.SetCalendarColor(s.ctx, s.client, calendar.Path, args[1])
+}
+
+func listEvents(opts *options, s *session, args []string) error {
+	start, end, err := parseRange(args, opts.from, opts.to, time.Now())
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, errUsage)
+	}
+	calendar, err := mycal.FindCalendar(s.ctx, s.client, s.homeset, opts.calendar)
+	if err != nil {
+		return err
+	}
+	events, err := mycal.ListEvents(s.ctx, s.client, calendar, start, end)
+	if err != nil {
+		return err
+	}
+	return writeEvents(s.out, opts.format, events)
+}
+
+func addEvent(opts *options, s *session, args []string) error {
+	if len(args) == 0 || opts.from == "" || opts.to == "" {
+		return errUsage
+	}
+	start, err := parseTime(opts.from)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, errUsage)
+	}
+	end, err := parseTime(opts.to)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, errUsage)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("event must end after it starts: %w", errUsage)
+	}
+
+	calendar, err := mycal.FindCalendar(s.ctx, s.client, s.homeset, opts.calendar)
+	if err != nil {
+		return err
+	}
+	// Local times have no valid TZID, so store them as UTC
+	event, err := mycal.GetEvent(strings.Join(args, " "), start.UTC(), end.UTC())
+	if err != nil {
+		return err
+	}
+	if err := mycal.PutEvent(s.ctx, s.client, calendar.Path, event); err != nil {
+		return err
+	}
+
+	uid, _ := event.Props.Text(ical.PropUID)
+	fmt.Fprintln(s.out, uid)
+	return nil
+}
+
+func showEvent(opts *options, s *session, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	calendar, err := mycal.FindCalendar(s.ctx, s.client, s.homeset, opts.calendar)
+	if err != nil {
+		return err
+	}
+	object, err := mycal.FindEvent(s.ctx, s.client, calendar, args[0])
+	if err != nil {
+		return err
+	}
+	return ical.NewEncoder(s.out).Encode(object.Data)
+}
+
+func deleteEvent(opts *options, s *session, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	calendar, err := mycal.FindCalendar(s.ctx, s.client, s.homeset, opts.calendar)
+	if err != nil {
+		return err
+	}
+	object, err := mycal.FindEvent(s.ctx, s.client, calendar, args[0])
+	if err != nil {
+		return err
+	}
+	return mycal.Delete(s.ctx, s.client, object.Path)
+}
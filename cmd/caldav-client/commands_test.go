@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trvita/caldav-client-yandex/caldav"
+	"github.com/trvita/caldav-client-yandex/mycal"
+)
+
+func newTestSession(t *testing.T) (*session, *bytes.Buffer) {
+	t.Helper()
+
+	b := caldav.NewMemoryBackend("/user/", "/user/calendars/")
+	ts := httptest.NewServer(&caldav.Handler{Backend: b})
+	t.Cleanup(ts.Close)
+
+	client, principal, ctx, err := mycal.CreateClientWithCredentials(ts.URL, "alice", "secret")
+	if err != nil {
+		t.Fatalf("CreateClientWithCredentials() = %v", err)
+	}
+	homeset, err := client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		t.Fatalf("FindCalendarHomeSet() = %v", err)
+	}
+	var out bytes.Buffer
+	return &session{ctx: ctx, client: client, homeset: homeset, out: &out}, &out
+}
+
+func TestLoginUnknownProfile(t *testing.T) {
+	opts := &options{profile: "missing", config: filepath.Join(t.TempDir(), "config.json")}
+	// An unknown profile is a lookup failure, not a missing resource
+	if _, err := login(opts); err == nil || errors.Is(err, mycal.ErrNotFound) {
+		t.Errorf("login() with unknown profile = %v, want an error other than ErrNotFound", err)
+	}
+}
+
+func TestCalendarCommands(t *testing.T) {
+	s, out := newTestSession(t)
+	opts := &options{format: formatText, description: "Meetings", color: "#FF0000"}
+
+	if err := createCalendar(opts, s, []string{"Work"}); err != nil {
+		t.Fatalf("calendars create = %v", err)
+	}
+	if err := renameCalendar(opts, s, []string{"Work", "Office"}); err != nil {
+		t.Fatalf("calendars rename = %v", err)
+	}
+	if err := recolorCalendar(opts, s, []string{"Office", "#00FF00"}); err != nil {
+		t.Fatalf("calendars recolor = %v", err)
+	}
+
+	out.Reset()
+	opts.format = formatCSV
+	if err := listCalendars(opts, s, nil); err != nil {
+		t.Fatalf("calendars list = %v", err)
+	}
+	want := "name,path,description,color,components\nOffice,/user/calendars/work/,Meetings,#00FF00,VEVENT\n"
+	if out.String() != want {
+		t.Errorf("calendars list = %q, want %q", out.String(), want)
+	}
+
+	if err := deleteCalendar(opts, s, []string{"Work"}); !errors.Is(err, mycal.ErrNotFound) {
+		t.Errorf("calendars delete with the old name = %v, want ErrNotFound", err)
+	}
+	if err := deleteCalendar(opts, s, []string{"Office"}); err != nil {
+		t.Fatalf("calendars delete = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		cmd  func(*options, *session, []string) error
+		args []string
+	}{
+		{"list", listCalendars, []string{"extra"}},
+		{"create", createCalendar, nil},
+		{"delete", deleteCalendar, []string{"a", "b"}},
+		{"rename", renameCalendar, []string{"Office", ""}},
+		{"recolor", recolorCalendar, []string{"Office"}},
+	} {
+		if err := tc.cmd(opts, s, tc.args); !errors.Is(err, errUsage) {
+			t.Errorf("calendars %v %q = %v, want a usage error", tc.name, tc.args, err)
+		}
+	}
+}
+
+func TestEventCommands(t *testing.T) {
+	s, out := newTestSession(t)
+	opts := &options{format: formatText}
+	if err := createCalendar(opts, s, []string{"Work"}); err != nil {
+		t.Fatalf("calendars create = %v", err)
+	}
+	opts.calendar = "Work"
+
+	opts.from, opts.to = "2024-01-02T09:00:00Z", "2024-01-02T10:00:00Z"
+	if err := addEvent(opts, s, []string{"Team", "standup"}); err != nil {
+		t.Fatalf("events add = %v", err)
+	}
+	uid := strings.TrimSpace(out.String())
+	if uid == "" {
+		t.Fatalf("events add didn't print the event UID")
+	}
+
+	out.Reset()
+	if err := showEvent(opts, s, []string{uid}); err != nil {
+		t.Fatalf("events show = %v", err)
+	}
+	if !strings.Contains(out.String(), "SUMMARY:Team standup") || !strings.Contains(out.String(), "DTSTART:20240102T090000Z") {
+		t.Errorf("events show = %q, want the standup event", out.String())
+	}
+
+	out.Reset()
+	opts.format = formatJSON
+	opts.from, opts.to = "2024-01-02T00:00:00Z", "2024-01-03T00:00:00Z"
+	if err := listEvents(opts, s, nil); err != nil {
+		t.Fatalf("events list = %v", err)
+	}
+	if !strings.Contains(out.String(), `"uid": "`+uid+`"`) {
+		t.Errorf("events list = %q, want event %v", out.String(), uid)
+	}
+
+	if err := deleteEvent(opts, s, []string{uid}); err != nil {
+		t.Fatalf("events delete = %v", err)
+	}
+	if err := showEvent(opts, s, []string{uid}); !errors.Is(err, mycal.ErrNotFound) {
+		t.Errorf("events show after delete = %v, want ErrNotFound", err)
+	}
+
+	for _, tc := range []struct {
+		from, to string
+		args     []string
+	}{
+		{"", "2024-01-02T10:00:00Z", []string{"No start"}},
+		{"2024-01-02T10:00:00Z", "2024-01-02T09:00:00Z", []string{"Backwards"}},
+		{"yesterday", "2024-01-02T09:00:00Z", []string{"Invalid"}},
+		{"2024-01-02T09:00:00Z", "2024-01-02T10:00:00Z", nil},
+	} {
+		opts.from, opts.to = tc.from, tc.to
+		if err := addEvent(opts, s, tc.args); !errors.Is(err, errUsage) {
+			t.Errorf("events add --from %q --to %q %q = %v, want a usage error", tc.from, tc.to, tc.args, err)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2024, 1, 17, 15, 30, 0, 0, time.Local)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 0, 0, 0, 0, time.Local)
+	}
+
+	for _, tc := range []struct {
+		words      []string
+		from, to   string
+		start, end time.Time
+	}{
+		{[]string{"today"}, "", "", day(1, 17), day(1, 18)},
+		{[]string{"tomorrow"}, "", "", day(1, 18), day(1, 19)},
+		{[]string{"this", "week"}, "", "", day(1, 15), day(1, 22)},
+		{[]string{"Next", "Week"}, "", "", day(1, 22), day(1, 29)},
+		{[]string{"month"}, "", "", day(1, 1), day(2, 1)},
+		{[]string{"next", "month"}, "", "", day(2, 1), day(3, 1)},
+		{nil, "2024-01-02", "", day(1, 2), time.Time{}},
+		{nil, "", "2024-01-02 15:04", time.Time{}, time.Date(2024, 1, 2, 15, 4, 0, 0, time.Local)},
+		{nil, "", "", time.Time{}, time.Time{}},
+	} {
+		start, end, err := parseRange(tc.words, tc.from, tc.to, now)
+		if err != nil {
+			t.Errorf("parseRange(%q, %q, %q) = %v", tc.words, tc.from, tc.to, err)
+		} else if !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("parseRange(%q, %q, %q) = %v, %v, want %v, %v", tc.words, tc.from, tc.to, start, end, tc.start, tc.end)
+		}
+	}
+
+	for _, tc := range []struct {
+		words    []string
+		from, to string
+	}{
+		{[]string{"yesterday"}, "", ""},
+		{[]string{"today"}, "2024-01-02", ""},
+		{nil, "2024-01-02", "2024-01-01"},
+		{nil, "soon", ""},
+	} {
+		if _, _, err := parseRange(tc.words, tc.from, tc.to, now); err == nil {
+			t.Errorf("parseRange(%q, %q, %q) succeeded", tc.words, tc.from, tc.to)
+		}
+	}
+}
@@ -1,12 +1,225 @@
 package main
 
-import "github.com/trvita/caldav-client-yandex/ui"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/trvita/caldav-client-yandex/mycal"
+	"github.com/trvita/caldav-client-yandex/ui"
+)
+
+// Exit codes
+const (
+	exitOK       = 0
+	exitFailure  = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+const usage = `usage: %s [options...] [command [arguments...]]
+
+Without a command, an interactive menu is started.
+
+Commands:
+  calendars list
+  calendars create [--description <text>] [--color <color>] <name>
+  calendars delete <name>
+  calendars rename <name> <new name>
+  calendars recolor <name> <color>
+  events list --calendar <name> [--from <time>] [--to <time>] [<range>]
+  events add --calendar <name> --from <time> --to <time> <summary>
+  events show --calendar <name> <uid>
+  events delete --calendar <name> <uid>
+
+Times are formatted as RFC 3339, "2006-01-02 15:04" or "2006-01-02", in
+local time unless specified otherwise.
+
+Event listings can be restricted to a range: today, tomorrow, this week,
+next week, this month or next month. Weeks start on Monday.
+
+Listings can be written as text, JSON, CSV, or raw iCalendar for events,
+using --format.
+
+Credentials are looked up, in order, in the profile selected with --profile
+or $CALDAV_PROFILE, in $CALDAV_USERNAME and $CALDAV_PASSWORD, in the first
+profile whose URL matches the server, and in the netrc file. Profiles are
+read from the config file, for instance:
+
+  [profile yandex]
+  url = https://caldav.yandex.ru
+  username = alice
+  app-password = xxxxxxxxxxxxxxxx
+
+If --user is set and doesn't match the stored credentials, or if no password
+is stored, the password is read from standard input. Otherwise, the user name
+and password are prompted for.
+
+Exit status is 0 on success, 1 on failure, 2 on usage errors and 3 if the
+calendar or event doesn't exist.
+
+Options:
+`
+
+type options struct {
+	url         string
+	urlSet      bool
+	user        string
+	profile     string
+	config      string
+	netrc       string
+	calendar    string
+	description string
+	color       string
+	from        string
+	to          string
+	format      string
+}
+
+// errUsage is returned by commands invoked with invalid arguments.
+var errUsage = errors.New("invalid usage")
 
 func main() {
-	// v, _ := uuid.NewV7()
-	// //uid := base64.NewEncoding("")
-	// bin, _ := v.MarshalBinary()
-	// val := base32.StdEncoding.EncodeToString([]byte(bin))
-	// fmt.Println(val)
-	ui.StartMenu("https://caldav.yandex.ru")
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	var opts options
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.StringVar(&opts.url, "url", "https://caldav.yandex.ru", "CalDAV server URL")
+	fs.StringVar(&opts.user, "user", "", "user name")
+	fs.StringVar(&opts.profile, "profile", "", "config profile name")
+	fs.StringVar(&opts.config, "config", "", "config file path (default is the user config directory)")
+	fs.StringVar(&opts.netrc, "netrc", "", "netrc file path (default is $NETRC or ~/.netrc)")
+	fs.StringVar(&opts.calendar, "calendar", "", "calendar name")
+	fs.StringVar(&opts.description, "description", "", "calendar description")
+	fs.StringVar(&opts.color, "color", "", "calendar color, such as \"#FF0000\"")
+	fs.StringVar(&opts.from, "from", "", "event start time, or start of the listed range")
+	fs.StringVar(&opts.to, "to", "", "event end time, or end of the listed range")
+	fs.StringVar(&opts.format, "format", formatText, "listing output format: text, json, csv or ics (events only)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), usage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		ui.StartMenu(opts.url)
+		return exitOK
+	}
+
+	// Allow flags to be specified after the command as well
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	command := fs.Args()[:2]
+	if err := fs.Parse(fs.Args()[2:]); err != nil {
+		return exitUsage
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "url" {
+			opts.urlSet = true
+		}
+	})
+
+	err := runCommand(&opts, command[0], command[1], fs.Args())
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		if err != errUsage {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		fs.Usage()
+		return exitUsage
+	case errors.Is(err, mycal.ErrNotFound):
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitNotFound
+	default:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitFailure
+	}
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// parseRange parses the time range of an event listing, either from a range
+// name split in words or from the --from and --to flags.
+func parseRange(words []string, from, to string, now time.Time) (start, end time.Time, err error) {
+	if len(words) > 0 {
+		if from != "" || to != "" {
+			return start, end, fmt.Errorf("a range can't be combined with --from or --to")
+		}
+		today := startOfDay(now)
+		// Monday is the first day of the week
+		week := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		switch strings.ToLower(strings.Join(words, " ")) {
+		case "today":
+			start = today
+			end = start.AddDate(0, 0, 1)
+		case "tomorrow":
+			start = today.AddDate(0, 0, 1)
+			end = start.AddDate(0, 0, 1)
+		case "this week", "week":
+			start = week
+			end = start.AddDate(0, 0, 7)
+		case "next week":
+			start = week.AddDate(0, 0, 7)
+			end = start.AddDate(0, 0, 7)
+		case "this month", "month":
+			start = month
+			end = start.AddDate(0, 1, 0)
+		case "next month":
+			start = month.AddDate(0, 1, 0)
+			end = start.AddDate(0, 1, 0)
+		default:
+			return start, end, fmt.Errorf("unknown range %q", strings.Join(words, " "))
+		}
+		return start, end, nil
+	}
+
+	if from != "" {
+		if start, err = parseTime(from); err != nil {
+			return start, end, err
+		}
+	}
+	if to != "" {
+		if end, err = parseTime(to); err != nil {
+			return start, end, err
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return start, end, fmt.Errorf("range must end after it starts")
+	}
+	return start, end, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
 }
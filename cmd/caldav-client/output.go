@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/trvita/caldav-client-yandex/caldav"
+	"github.com/trvita/caldav-client-yandex/mycal"
+	"github.com/trvita/go-ical"
+)
+
+// Output formats
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatICS  = "ics"
+)
+
+type calendarOutput struct {
+	Name                  string   `json:"name"`
+	Path                  string   `json:"path"`
+	Description           string   `json:"description,omitempty"`
+	Color                 string   `json:"color,omitempty"`
+	SupportedComponentSet []string `json:"supportedComponentSet,omitempty"`
+}
+
+type eventOutput struct {
+	UID         string    `json:"uid"`
+	Summary     string    `json:"summary"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	AllDay      bool      `json:"allDay"`
+	Location    string    `json:"location,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Path        string    `json:"path"`
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeCalendars(w io.Writer, format string, calendars []caldav.Calendar) error {
+	l := make([]calendarOutput, 0, len(calendars))
+	for _, cal := range calendars {
+		l = append(l, calendarOutput{
+			Name:                  cal.Name,
+			Path:                  cal.Path,
+			Description:           cal.Description,
+			Color:                 cal.Color,
+			SupportedComponentSet: cal.SupportedComponentSet,
+		})
+	}
+
+	switch format {
+	case formatText:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, cal := range l {
+			fmt.Fprintf(tw, "%s\t%s", cal.Name, cal.Path)
+			if cal.Color != "" {
+				fmt.Fprintf(tw, "\t%s", cal.Color)
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	case formatJSON:
+		return writeJSON(w, l)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "path", "description", "color", "components"})
+		for _, cal := range l {
+			cw.Write([]string{cal.Name, cal.Path, cal.Description, cal.Color, strings.Join(cal.SupportedComponentSet, " ")})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported output format %q for calendars: %w", format, errUsage)
+	}
+}
+
+func formatEventTime(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+func writeEvents(w io.Writer, format string, events []mycal.Event) error {
+	l := make([]eventOutput, 0, len(events))
+	for _, event := range events {
+		l = append(l, eventOutput{
+			UID:         event.UID,
+			Summary:     event.Summary,
+			Start:       event.Start,
+			End:         event.End,
+			AllDay:      event.AllDay,
+			Location:    event.Location,
+			Description: event.Description,
+			Path:        event.Path,
+		})
+	}
+
+	switch format {
+	case formatText:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, event := range l {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", formatEventTime(event.Start, event.AllDay),
+				formatEventTime(event.End, event.AllDay), event.Summary, event.UID)
+		}
+		return tw.Flush()
+	case formatJSON:
+		return writeJSON(w, l)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"uid", "summary", "start", "end", "all_day", "location", "description", "path"})
+		for _, event := range l {
+			cw.Write([]string{
+				event.UID,
+				event.Summary,
+				event.Start.Format(time.RFC3339),
+				event.End.Format(time.RFC3339),
+				strconv.FormatBool(event.AllDay),
+				event.Location,
+				event.Description,
+				event.Path,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case formatICS:
+		// Events from the same calendar object (e.g. recurrence overrides)
+		// are only written once
+		written := make(map[string]bool)
+		enc := ical.NewEncoder(w)
+		for _, event := range events {
+			if written[event.Path] {
+				continue
+			}
+			written[event.Path] = true
+			if err := enc.Encode(event.Object); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q for events: %w", format, errUsage)
+	}
+}
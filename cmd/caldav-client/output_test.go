@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/trvita/caldav-client-yandex/caldav"
+	"github.com/trvita/caldav-client-yandex/mycal"
+	"github.com/trvita/go-ical"
+)
+
+var testCalendars = []caldav.Calendar{
+	{
+		Path:                  "/user/calendars/work/",
+		Name:                  "Work",
+		Description:           "Meetings, reviews",
+		Color:                 "#FF0000",
+		SupportedComponentSet: []string{"VEVENT", "VTODO"},
+	},
+	{
+		Path: "/user/calendars/home/",
+		Name: "Home",
+	},
+}
+
+func newTestEvents(t *testing.T) []mycal.Event {
+	t.Helper()
+
+	loc := time.FixedZone("MSK", 3*60*60)
+	var events []mycal.Event
+	for _, e := range []mycal.Event{
+		{
+			Path:        "/user/calendars/work/review.ics",
+			UID:         "review",
+			Summary:     `Review "Q1", part 2`,
+			Description: "Slides\nand notes",
+			Location:    "Room 1",
+			Start:       time.Date(2024, 1, 2, 9, 0, 0, 0, loc),
+			End:         time.Date(2024, 1, 2, 10, 30, 0, 0, loc),
+		},
+		{
+			Path:    "/user/calendars/work/offsite.ics",
+			UID:     "offsite",
+			Summary: "Offsite",
+			Start:   time.Date(2024, 1, 3, 0, 0, 0, 0, loc),
+			End:     time.Date(2024, 1, 4, 0, 0, 0, 0, loc),
+			AllDay:  true,
+		},
+	} {
+		event := ical.NewEvent()
+		event.Props.SetText(ical.PropUID, e.UID)
+		event.Props.SetDateTime(ical.PropDateTimeStamp, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		event.Props.SetText(ical.PropSummary, e.Summary)
+		cal := ical.NewCalendar()
+		cal.Props.SetText(ical.PropVersion, "2.0")
+		cal.Props.SetText(ical.PropProductID, "-//trvita//EN")
+		cal.Children = append(cal.Children, event.Component)
+
+		e.Object, e.Event = cal, event
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestWriteCalendars(t *testing.T) {
+	for _, tc := range []struct {
+		format, want string
+	}{
+		{formatText, `Work  /user/calendars/work/  #FF0000
+Home  /user/calendars/home/
+`},
+		{formatJSON, `[
+  {
+    "name": "Work",
+    "path": "/user/calendars/work/",
+    "description": "Meetings, reviews",
+    "color": "#FF0000",
+    "supportedComponentSet": [
+      "VEVENT",
+      "VTODO"
+    ]
+  },
+  {
+    "name": "Home",
+    "path": "/user/calendars/home/"
+  }
+]
+`},
+		{formatCSV, `name,path,description,color,components
+Work,/user/calendars/work/,"Meetings, reviews",#FF0000,VEVENT VTODO
+Home,/user/calendars/home/,,,
+`},
+	} {
+		var buf bytes.Buffer
+		if err := writeCalendars(&buf, tc.format, testCalendars); err != nil {
+			t.Fatalf("writeCalendars(%v) = %v", tc.format, err)
+		}
+		if buf.String() != tc.want {
+			t.Errorf("writeCalendars(%v) = \n%s\nwant\n%s", tc.format, buf.String(), tc.want)
+		}
+	}
+
+	if err := writeCalendars(&bytes.Buffer{}, formatICS, testCalendars); !errors.Is(err, errUsage) {
+		t.Errorf("writeCalendars(%v) = %v, want a usage error", formatICS, err)
+	}
+}
+
+func TestWriteEvents(t *testing.T) {
+	events := newTestEvents(t)
+
+	for _, tc := range []struct {
+		format, want string
+	}{
+		{formatText, `2024-01-02 09:00  2024-01-02 10:30  Review "Q1", part 2  review
+2024-01-03        2024-01-04        Offsite              offsite
+`},
+		{formatJSON, `[
+  {
+    "uid": "review",
+    "summary": "Review \"Q1\", part 2",
+    "start": "2024-01-02T09:00:00+03:00",
+    "end": "2024-01-02T10:30:00+03:00",
+    "allDay": false,
+    "location": "Room 1",
+    "description": "Slides\nand notes",
+    "path": "/user/calendars/work/review.ics"
+  },
+  {
+    "uid": "offsite",
+    "summary": "Offsite",
+    "start": "2024-01-03T00:00:00+03:00",
+    "end": "2024-01-04T00:00:00+03:00",
+    "allDay": true,
+    "path": "/user/calendars/work/offsite.ics"
+  }
+]
+`},
+		{formatCSV, `uid,summary,start,end,all_day,location,description,path
+review,"Review ""Q1"", part 2",2024-01-02T09:00:00+03:00,2024-01-02T10:30:00+03:00,false,Room 1,"Slides
+and notes",/user/calendars/work/review.ics
+offsite,Offsite,2024-01-03T00:00:00+03:00,2024-01-04T00:00:00+03:00,true,,,/user/calendars/work/offsite.ics
+`},
+		{formatICS, "BEGIN:VCALENDAR\r\n" +
+			"PRODID:-//trvita//EN\r\n" +
+			"VERSION:2.0\r\n" +
+			"BEGIN:VEVENT\r\n" +
+			"DTSTAMP:20240101T000000Z\r\n" +
+			"SUMMARY:Review \"Q1\"\\, part 2\r\n" +
+			"UID:review\r\n" +
+			"END:VEVENT\r\n" +
+			"END:VCALENDAR\r\n" +
+			"BEGIN:VCALENDAR\r\n" +
+			"PRODID:-//trvita//EN\r\n" +
+			"VERSION:2.0\r\n" +
+			"BEGIN:VEVENT\r\n" +
+			"DTSTAMP:20240101T000000Z\r\n" +
+			"SUMMARY:Offsite\r\n" +
+			"UID:offsite\r\n" +
+			"END:VEVENT\r\n" +
+			"END:VCALENDAR\r\n"},
+	} {
+		var buf bytes.Buffer
+		if err := writeEvents(&buf, tc.format, events); err != nil {
+			t.Fatalf("writeEvents(%v) = %v", tc.format, err)
+		}
+		if buf.String() != tc.want {
+			t.Errorf("writeEvents(%v) = \n%s\nwant\n%s", tc.format, buf.String(), tc.want)
+		}
+	}
+
+	if err := writeEvents(&bytes.Buffer{}, "xml", events); !errors.Is(err, errUsage) {
+		t.Errorf("writeEvents(xml) = %v, want a usage error", err)
+	}
+}
+
+func TestWriteEventsICSDeduplicates(t *testing.T) {
+	events := newTestEvents(t)
+	// Occurrences of a recurring event share their calendar object
+	events[1].Path, events[1].Object = events[0].Path, events[0].Object
+
+	var buf bytes.Buffer
+	if err := writeEvents(&buf, formatICS, events); err != nil {
+		t.Fatalf("writeEvents() = %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("BEGIN:VCALENDAR")); n != 1 {
+		t.Errorf("writeEvents() wrote %v calendar objects, want 1", n)
+	}
+}
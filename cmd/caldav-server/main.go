@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trvita/caldav-client-yandex"
+	"github.com/trvita/caldav-client-yandex/caldav"
+	"github.com/trvita/caldav-client-yandex/carddav"
+)
+
+const (
+	principalPrefix = "/principals"
+	caldavPrefix    = "/caldav"
+	carddavPrefix   = "/carddav"
+)
+
+type user struct {
+	name     string
+	password string
+
+	principalPath string
+	caldav        *caldav.Handler
+	carddav       *carddav.Handler
+}
+
+// loadUsers reads a users file. Each non-empty line which isn't a comment
+// contains a user name and a password separated by a colon.
+func loadUsers(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, password, ok := strings.Cut(line, ":")
+		if !ok || name == "" || strings.ContainsAny(name, "/\\") {
+			return nil, fmt.Errorf("%v:%v: expected \"user:password\"", filename, i)
+		}
+		if _, dup := users[name]; dup {
+			return nil, fmt.Errorf("%v:%v: duplicate user %q", filename, i, name)
+		}
+		users[name] = password
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%v: no users defined", filename)
+	}
+	return users, nil
+}
+
+func newUser(dir, name, password string) *user {
+	principalPath := principalPrefix + "/" + name + "/"
+	return &user{
+		name:          name,
+		password:      password,
+		principalPath: principalPath,
+		caldav: &caldav.Handler{
+			Backend: caldav.NewLocalBackend(filepath.Join(dir, name, "calendars"),
+				principalPath, caldavPrefix+"/"+name+"/calendars/"),
+			Prefix: caldavPrefix,
+		},
+		carddav: &carddav.Handler{
+			Backend: carddav.NewLocalBackend(filepath.Join(dir, name, "contacts"),
+				principalPath, carddavPrefix+"/"+name+"/contacts/"),
+			Prefix: carddavPrefix,
+		},
+	}
+}
+
+type server struct {
+	users map[string]*user
+}
+
+func (s *server) authenticate(r *http.Request) *user {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+	u := s.users[name]
+	if u == nil || subtle.ConstantTimeCompare([]byte(password), []byte(u.password)) != 1 {
+		return nil
+	}
+	return u
+}
+
+// ownsPath returns true if p is below prefix and belongs to the user, or is
+// the prefix itself.
+func (u *user) ownsPath(prefix, p string) bool {
+	p = strings.TrimPrefix(p, prefix)
+	if p == "" || p == "/" {
+		return true
+	}
+	return p == "/"+u.name || strings.HasPrefix(p, "/"+u.name+"/")
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u := s.authenticate(r)
+	if u == nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="caldav-server", charset="UTF-8"`)
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	p := r.URL.Path
+	switch {
+	case p == "/.well-known/caldav" || p == "/.well-known/carddav":
+		http.Redirect(w, r, u.principalPath, http.StatusPermanentRedirect)
+	case p == "/" || p == u.principalPath || p == strings.TrimSuffix(u.principalPath, "/"):
+		webdav.ServePrincipal(w, r, &webdav.ServePrincipalOptions{
+			CurrentUserPrincipalPath: u.principalPath,
+			HomeSets: []webdav.BackendSuppliedHomeSet{
+				caldav.NewCalendarHomeSet(caldavPrefix + "/" + u.name + "/calendars/"),
+				carddav.NewAddressBookHomeSet(carddavPrefix + "/" + u.name + "/contacts/"),
+			},
+			Capabilities: []webdav.Capability{
+				caldav.CapabilityCalendar,
+				carddav.CapabilityAddressBook,
+			},
+		})
+	case p == caldavPrefix || strings.HasPrefix(p, caldavPrefix+"/"):
+		if !u.ownsPath(caldavPrefix, p) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		u.caldav.ServeHTTP(w, r)
+	case p == carddavPrefix || strings.HasPrefix(p, carddavPrefix+"/"):
+		if !u.ownsPath(carddavPrefix, p) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		u.carddav.ServeHTTP(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func main() {
+	var addr, usersFilename string
+	flag.StringVar(&addr, "addr", ":8080", "listening address")
+	flag.StringVar(&usersFilename, "users", "users.conf", "users file, with one \"user:password\" entry per line")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [options...] [directory]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := flag.Arg(0)
+	if dir == "" {
+		dir = "."
+	}
+
+	passwords, err := loadUsers(usersFilename)
+	if err != nil {
+		log.Fatalf("failed to load users: %v", err)
+	}
+
+	s := server{users: make(map[string]*user)}
+	for name, password := range passwords {
+		s.users[name] = newUser(dir, name, password)
+	}
+
+	log.Printf("CalDAV/CardDAV server listening on %v", addr)
+	log.Fatal(http.ListenAndServe(addr, &s))
+}
@@ -194,6 +194,24 @@ func (c *Client) PropFindFlat(ctx context.Context, path string, propfind *PropFi
 	return &ms.Responses[0], nil
 }
 
+// PropPatch performs a PROPPATCH request.
+func (c *Client) PropPatch(ctx context.Context, path string, update *PropertyUpdate) (*Response, error) {
+	req, err := c.NewXMLRequest("PROPPATCH", path, update)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := c.DoMultiStatus(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ms.Responses) != 1 {
+		return nil, fmt.Errorf("PROPPATCH returned %d responses", len(ms.Responses))
+	}
+	return &ms.Responses[0], nil
+}
+
 func parseCommaSeparatedSet(values []string, upper bool) map[string]bool {
 	m := make(map[string]bool)
 	for _, v := range values {
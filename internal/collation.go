@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Collations registered in RFC 4790 and RFC 5051, used by CalDAV and
+// CardDAV text-match elements.
+const (
+	CollationOctet          = "i;octet"
+	CollationASCIICasemap   = "i;ascii-casemap"
+	CollationUnicodeCasemap = "i;unicode-casemap"
+)
+
+// IsSupportedCollation reports whether FoldCollation supports the collation.
+func IsSupportedCollation(collation string) bool {
+	switch collation {
+	case CollationOctet, CollationASCIICasemap, CollationUnicodeCasemap:
+		return true
+	}
+	return false
+}
+
+// FoldCollation maps s so that strings which are equal under the collation
+// are equal byte-wise. The Unicode decomposition step of i;unicode-casemap is
+// not applied, only the case mapping.
+func FoldCollation(collation, s string) (string, error) {
+	switch collation {
+	case CollationOctet:
+		return s, nil
+	case CollationASCIICasemap:
+		return strings.Map(func(r rune) rune {
+			if 'a' <= r && r <= 'z' {
+				return r - 'a' + 'A'
+			}
+			return r
+		}, s), nil
+	case CollationUnicodeCasemap:
+		return strings.Map(unicode.ToTitle, s), nil
+	}
+	return "", fmt.Errorf("webdav: unsupported collation %q", collation)
+}
@@ -22,6 +22,10 @@ var (
 	GetETagName          = xml.Name{Namespace, "getetag"}
 
 	CurrentUserPrincipalName = xml.Name{Namespace, "current-user-principal"}
+
+	SyncCollectionName = xml.Name{Namespace, "sync-collection"}
+	SyncTokenName      = xml.Name{Namespace, "sync-token"}
+	ValidSyncTokenName = xml.Name{Namespace, "valid-sync-token"}
 )
 
 type Status struct {
@@ -244,6 +248,26 @@ type PropStat struct {
 	Error               *Error   `xml:"error,omitempty"`
 }
 
+// Err returns an error if the status of propstat isn't successful.
+func (propstat *PropStat) Err() error {
+	if propstat.Status.Code/100 == 2 {
+		return nil
+	}
+
+	var err error
+	if propstat.Error != nil {
+		err = propstat.Error
+	}
+	if propstat.ResponseDescription != "" {
+		if err != nil {
+			err = fmt.Errorf("%v (%w)", propstat.ResponseDescription, err)
+		} else {
+			err = fmt.Errorf("%v", propstat.ResponseDescription)
+		}
+	}
+	return &HTTPError{Code: propstat.Status.Code, Err: err}
+}
+
 // https://tools.ietf.org/html/rfc4918#section-14.18
 type Prop struct {
 	XMLName xml.Name      `xml:"DAV: prop"`
@@ -418,6 +442,60 @@ func (err *Error) Error() string {
 	return string(b)
 }
 
+// Condition returns the first precondition or postcondition element of err
+// in the provided XML namespace, or nil if there is none.
+func (err *Error) Condition(space string) *RawXMLValue {
+	for i := range err.Raw {
+		if name, ok := err.Raw[i].XMLName(); ok && name.Space == space {
+			return &err.Raw[i]
+		}
+	}
+	return nil
+}
+
+// NewPreconditionError returns a 409 Conflict error reporting the
+// precondition element raw.
+func NewPreconditionError(raw *RawXMLValue) error {
+	return &HTTPError{
+		Code: http.StatusConflict,
+		Err:  &Error{Raw: []RawXMLValue{*raw}},
+	}
+}
+
+// NewInvalidSyncTokenError returns a 403 Forbidden error with a
+// DAV:valid-sync-token precondition, telling clients to restart with an
+// initial synchronization. See RFC 6578 section 3.2.
+func NewInvalidSyncTokenError() error {
+	return &HTTPError{
+		Code: http.StatusForbidden,
+		Err:  &Error{Raw: []RawXMLValue{*NewRawXMLElement(ValidSyncTokenName, nil, nil)}},
+	}
+}
+
+// DecodePrecondition looks for a precondition element in the provided XML
+// namespace in err. It returns the element name, along with the path in its
+// DAV:href child if there is one, such as the conflicting resource of a
+// no-uid-conflict precondition.
+func DecodePrecondition(err error, space string) (name, href string, ok bool) {
+	var davErr *Error
+	if !errors.As(err, &davErr) {
+		return "", "", false
+	}
+	raw := davErr.Condition(space)
+	if raw == nil {
+		return "", "", false
+	}
+	xmlName, _ := raw.XMLName()
+
+	var conflict struct {
+		Href Href `xml:"DAV: href"`
+	}
+	if raw.Decode(&conflict) == nil {
+		href = conflict.Href.Path
+	}
+	return xmlName.Local, href, true
+}
+
 // https://tools.ietf.org/html/rfc4918#section-15.2
 type DisplayName struct {
 	XMLName xml.Name `xml:"DAV: displayname"`
@@ -438,6 +516,86 @@ type PropertyUpdate struct {
 	Set     []Set    `xml:"set"`
 }
 
+// NewPropertyUpdate creates a PROPPATCH request body setting the properties
+// in set and removing the properties in remove.
+func NewPropertyUpdate(set, remove []interface{}) (*PropertyUpdate, error) {
+	var update PropertyUpdate
+	if len(set) > 0 {
+		prop, err := EncodeProp(set...)
+		if err != nil {
+			return nil, err
+		}
+		update.Set = []Set{{Prop: *prop}}
+	}
+	if len(remove) > 0 {
+		prop, err := EncodeProp(remove...)
+		if err != nil {
+			return nil, err
+		}
+		update.Remove = []Remove{{Prop: *prop}}
+	}
+	return &update, nil
+}
+
+// PropertyUpdateValues collects the properties to set and remove in a
+// PROPPATCH request, see NewPropertyUpdate.
+type PropertyUpdateValues struct {
+	Set, Remove []interface{}
+}
+
+// Add sets the property created by newProp to *v, or removes it if *v is
+// empty. Nothing is changed if v is nil.
+func (values *PropertyUpdateValues) Add(v *string, newProp func(s string) interface{}) {
+	if v == nil {
+		return
+	} else if *v == "" {
+		values.Remove = append(values.Remove, newProp(""))
+	} else {
+		values.Set = append(values.Set, newProp(*v))
+	}
+}
+
+// IsEmpty returns true if there are no properties to update.
+func (values *PropertyUpdateValues) IsEmpty() bool {
+	return len(values.Set) == 0 && len(values.Remove) == 0
+}
+
+// DecodePropertyUpdate calls f with the status of each property in a
+// PROPPATCH response. The returned error describes the property which caused
+// the update to fail, rather than the ones which failed as a consequence.
+// Errors are prefixed with the name of the calling package.
+func DecodePropertyUpdate(resp *Response, prefix string, f func(name xml.Name, code int, err error)) error {
+	if err := resp.Err(); err != nil {
+		return err
+	}
+
+	var failed, dependent error
+	for i := range resp.PropStats {
+		propstat := &resp.PropStats[i]
+		err := propstat.Err()
+		for _, raw := range propstat.Prop.Raw {
+			name, _ := raw.XMLName()
+			f(name, propstat.Status.Code, err)
+			if err == nil {
+				continue
+			}
+			err := fmt.Errorf("%v: failed to update property %q: %w", prefix, name.Local, err)
+			if propstat.Status.Code == http.StatusFailedDependency {
+				if dependent == nil {
+					dependent = err
+				}
+			} else if failed == nil {
+				failed = err
+			}
+		}
+	}
+
+	if failed != nil {
+		return failed
+	}
+	return dependent
+}
+
 // https://tools.ietf.org/html/rfc4918#section-14.23
 type Remove struct {
 	XMLName xml.Name `xml:"DAV: remove"`
@@ -459,6 +617,12 @@ type SyncCollectionQuery struct {
 	Prop      *Prop    `xml:"prop"`
 }
 
+// https://tools.ietf.org/html/rfc6578#section-6.2
+type SyncToken struct {
+	XMLName xml.Name `xml:"DAV: sync-token"`
+	Token   string   `xml:",chardata"`
+}
+
 // https://tools.ietf.org/html/rfc5323#section-5.17
 type Limit struct {
 	XMLName  xml.Name `xml:"DAV: limit"`
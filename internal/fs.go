@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CleanPath returns the shortest absolute path equivalent to p.
+func CleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// ErrFromOS maps errors from the os package to HTTP errors.
+func ErrFromOS(err error) error {
+	if os.IsNotExist(err) {
+		return &HTTPError{Code: http.StatusNotFound, Err: err}
+	} else if os.IsPermission(err) {
+		return &HTTPError{Code: http.StatusForbidden, Err: err}
+	} else if os.IsTimeout(err) {
+		return &HTTPError{Code: http.StatusServiceUnavailable, Err: err}
+	} else {
+		return err
+	}
+}
+
+// FileETag returns the ETag of a local file, computed from its modification
+// time and size. See webdav.LocalFileSystem.
+func FileETag(fi os.FileInfo) string {
+	return fmt.Sprintf("%x%x", fi.ModTime().UnixNano(), fi.Size())
+}
+
+// StatETag returns the ETag of the file at filename, or an empty string if it
+// doesn't exist.
+func StatETag(filename string) (string, error) {
+	fi, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", ErrFromOS(err)
+	}
+	return FileETag(fi), nil
+}
+
+// IsValidLocalName reports whether name can be used as the name of a file
+// stored by a filesystem-backed backend. Hidden files are reserved for
+// metadata.
+func IsValidLocalName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") &&
+		!strings.ContainsRune(name, filepath.Separator) && !strings.Contains(name, "\x00")
+}
+
+// WriteFileAtomic writes data to the file name in dir, making sure readers
+// never see a partially written file.
+func WriteFileAtomic(dir, name string, data []byte) error {
+	f, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return ErrFromOS(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return ErrFromOS(os.Rename(f.Name(), filepath.Join(dir, name)))
+}
+
+// CheckConditionalMatch checks the If-None-Match and If-Match header values
+// against the ETag of an existing resource, which is empty if the resource
+// doesn't exist.
+func CheckConditionalMatch(ifNoneMatch, ifMatch, etag string) error {
+	if ifNoneMatch != "" && etag != "" {
+		if ifNoneMatch == "*" {
+			return HTTPErrorf(http.StatusPreconditionFailed, "webdav: resource already exists")
+		}
+		var want ETag
+		if err := want.UnmarshalText([]byte(ifNoneMatch)); err != nil {
+			return HTTPErrorf(http.StatusBadRequest, "webdav: invalid If-None-Match header: %v", err)
+		}
+		if string(want) == etag {
+			return HTTPErrorf(http.StatusPreconditionFailed, "webdav: resource ETag matches If-None-Match")
+		}
+	}
+
+	if ifMatch != "" {
+		if etag == "" {
+			return HTTPErrorf(http.StatusPreconditionFailed, "webdav: resource doesn't exist")
+		}
+		if ifMatch != "*" {
+			var want ETag
+			if err := want.UnmarshalText([]byte(ifMatch)); err != nil {
+				return HTTPErrorf(http.StatusBadRequest, "webdav: invalid If-Match header: %v", err)
+			}
+			if string(want) != etag {
+				return HTTPErrorf(http.StatusPreconditionFailed, "webdav: resource ETag doesn't match If-Match")
+			}
+		}
+	}
+
+	return nil
+}
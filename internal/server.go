@@ -61,6 +61,31 @@ func ServeMultiStatus(w http.ResponseWriter, ms *MultiStatus) error {
 	return ServeXML(w).Encode(ms)
 }
 
+// ServeSyncCollection writes the multistatus response of a sync-collection
+// REPORT on the collection at path, as described in RFC 6578 section 3.
+// updated holds the responses for changed members and deleted the paths of
+// removed members. If truncated is set, a 507 response for the collection
+// tells the client to issue another request.
+func ServeSyncCollection(w http.ResponseWriter, path string, updated []Response, deleted []string, truncated bool, syncToken string) error {
+	resps := updated
+	for _, p := range deleted {
+		resps = append(resps, Response{
+			Hrefs:  []Href{{Path: p}},
+			Status: &Status{Code: http.StatusNotFound},
+		})
+	}
+	if truncated {
+		resps = append(resps, Response{
+			Hrefs:  []Href{{Path: path}},
+			Status: &Status{Code: http.StatusInsufficientStorage},
+		})
+	}
+
+	ms := NewMultiStatus(resps...)
+	ms.SyncToken = syncToken
+	return ServeMultiStatus(w, ms)
+}
+
 type Backend interface {
 	Options(r *http.Request) (caps []string, allow []string, err error)
 	HeadGet(w http.ResponseWriter, r *http.Request) error
@@ -0,0 +1,287 @@
+package mycal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables holding credentials.
+const (
+	EnvUsername = "CALDAV_USERNAME"
+	EnvPassword = "CALDAV_PASSWORD"
+	EnvProfile  = "CALDAV_PROFILE"
+)
+
+// ErrNoCredentials is returned by FindCredentials when no stored credentials
+// match the server.
+var ErrNoCredentials = errors.New("no stored credentials")
+
+// Credentials holds the user name and password used to log in to a server.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Profile is a named set of settings for a server, read from the config
+// file.
+type Profile struct {
+	Name     string
+	URL      string
+	Username string
+	// Password is usually an application-specific password generated by
+	// the server, rather than the account password
+	Password string
+}
+
+// DefaultConfigPath returns the path of the config file holding profiles.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "caldav-client", "config"), nil
+}
+
+// DefaultNetrcPath returns the path of the netrc file, which can be
+// overridden with the NETRC environment variable.
+func DefaultNetrcPath() (string, error) {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// LoadProfiles reads profiles from a config file. The file is made of
+// sections, each starting with a "[profile <name>]" line and followed by
+// "key = value" lines. Supported keys are url, username and app-password.
+// Empty lines and lines starting with "#" or ";" are ignored.
+//
+// A missing file isn't an error.
+func LoadProfiles(filename string) ([]Profile, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		profiles []Profile
+		cur      *Profile
+	)
+	scanner := bufio.NewScanner(f)
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			fields := strings.Fields(strings.Trim(line, "[]"))
+			if len(fields) != 2 || fields[0] != "profile" {
+				return nil, fmt.Errorf("%v:%v: expected \"[profile <name>]\"", filename, i)
+			}
+			profiles = append(profiles, Profile{Name: fields[1]})
+			cur = &profiles[len(profiles)-1]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%v:%v: expected \"key = value\"", filename, i)
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("%v:%v: setting outside of a profile", filename, i)
+		}
+		value = strings.TrimSpace(value)
+		switch key = strings.TrimSpace(key); key {
+		case "url":
+			cur.URL = value
+		case "username":
+			cur.Username = value
+		case "app-password":
+			cur.Password = value
+		default:
+			return nil, fmt.Errorf("%v:%v: unknown setting %q", filename, i, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// FindProfile returns the profile with the provided name.
+func FindProfile(profiles []Profile, name string) (*Profile, error) {
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("profile %s %w", name, ErrNotFound)
+}
+
+// LoadNetrc reads the credentials for host from a netrc file. If no machine
+// entry matches host, the default entry is used, if any. A missing file
+// isn't an error.
+func LoadNetrc(filename, host string) (*Credentials, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var (
+		match, def *Credentials
+		cur        *Credentials
+	)
+	tokens := strings.Fields(string(data))
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			i++
+			if i >= len(tokens) {
+				return nil, fmt.Errorf("%v: missing machine name", filename)
+			}
+			cur = &Credentials{}
+			if match == nil && strings.EqualFold(tokens[i], host) {
+				match = cur
+			}
+		case "default":
+			cur = &Credentials{}
+			def = cur
+		case "login", "password", "account":
+			i++
+			if i >= len(tokens) {
+				return nil, fmt.Errorf("%v: missing value for %q", filename, tokens[i-1])
+			}
+			if cur == nil {
+				return nil, fmt.Errorf("%v: %q outside of a machine entry", filename, tokens[i-1])
+			}
+			switch tokens[i-1] {
+			case "login":
+				cur.Username = tokens[i]
+			case "password":
+				cur.Password = tokens[i]
+			}
+		case "macdef":
+			// Macros span until the next empty line, which the tokenizer
+			// can't see, and don't hold credentials anyway
+			return firstCredentials(match, def), nil
+		}
+	}
+	return firstCredentials(match, def), nil
+}
+
+func firstCredentials(l ...*Credentials) *Credentials {
+	for _, c := range l {
+		if c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// CredentialOptions describes where FindCredentials looks for credentials.
+type CredentialOptions struct {
+	// Profile selects a profile by name. If empty, the CALDAV_PROFILE
+	// environment variable is used, then the first profile whose URL has
+	// the same host as the server.
+	Profile string
+	// ConfigPath and NetrcPath override the default file locations.
+	ConfigPath string
+	NetrcPath  string
+}
+
+func (opts *CredentialOptions) loadProfiles() ([]Profile, error) {
+	configPath := opts.ConfigPath
+	if configPath == "" {
+		var err error
+		if configPath, err = DefaultConfigPath(); err != nil {
+			return nil, err
+		}
+	}
+	return LoadProfiles(configPath)
+}
+
+// SelectedProfile returns the profile explicitly selected by opts.Profile or
+// the CALDAV_PROFILE environment variable, or nil if there is none.
+func (opts *CredentialOptions) SelectedProfile() (*Profile, error) {
+	name := opts.Profile
+	if name == "" {
+		name = os.Getenv(EnvProfile)
+	}
+	if name == "" {
+		return nil, nil
+	}
+	profiles, err := opts.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	return FindProfile(profiles, name)
+}
+
+// FindCredentials looks up stored credentials for the server at serverURL.
+// An explicitly selected profile takes precedence, then the CALDAV_USERNAME
+// and CALDAV_PASSWORD environment variables, then the first profile matching
+// the server host, then the netrc file. ErrNoCredentials is returned if none
+// match.
+//
+// The returned password may be empty if a profile doesn't specify one.
+func FindCredentials(serverURL string, opts *CredentialOptions) (*Credentials, error) {
+	if opts == nil {
+		opts = new(CredentialOptions)
+	}
+
+	if p, err := opts.SelectedProfile(); err != nil {
+		return nil, err
+	} else if p != nil {
+		return &Credentials{Username: p.Username, Password: p.Password}, nil
+	}
+
+	if username, password := os.Getenv(EnvUsername), os.Getenv(EnvPassword); username != "" && password != "" {
+		return &Credentials{Username: username, Password: password}, nil
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := opts.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		pu, err := url.Parse(p.URL)
+		if err == nil && p.URL != "" && p.Username != "" && strings.EqualFold(pu.Hostname(), u.Hostname()) {
+			return &Credentials{Username: p.Username, Password: p.Password}, nil
+		}
+	}
+
+	netrcPath := opts.NetrcPath
+	if netrcPath == "" {
+		if netrcPath, err = DefaultNetrcPath(); err != nil {
+			return nil, err
+		}
+	}
+	creds, err := LoadNetrc(netrcPath, u.Hostname())
+	if err != nil {
+		return nil, err
+	} else if creds != nil && creds.Username != "" {
+		return creds, nil
+	}
+
+	return nil, ErrNoCredentials
+}
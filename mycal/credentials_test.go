@@ -0,0 +1,196 @@
+package mycal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name, data string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+const testConfig = `# Accounts
+[profile yandex]
+url = https://caldav.yandex.ru
+username = alice
+app-password = yandex-secret
+
+; Self-hosted server, password prompted
+[profile home]
+url=https://dav.example.org/calendars/
+username=bob
+`
+
+func TestLoadProfiles(t *testing.T) {
+	profiles, err := LoadProfiles(writeTestFile(t, "config", testConfig))
+	if err != nil {
+		t.Fatalf("LoadProfiles() = %v", err)
+	}
+	want := []Profile{
+		{Name: "yandex", URL: "https://caldav.yandex.ru", Username: "alice", Password: "yandex-secret"},
+		{Name: "home", URL: "https://dav.example.org/calendars/", Username: "bob"},
+	}
+	if !reflect.DeepEqual(profiles, want) {
+		t.Errorf("LoadProfiles() = %+v, want %+v", profiles, want)
+	}
+
+	if p, err := FindProfile(profiles, "home"); err != nil || p.Username != "bob" {
+		t.Errorf("FindProfile(home) = %+v, %v", p, err)
+	}
+	if _, err := FindProfile(profiles, "work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindProfile(work) = %v, want ErrNotFound", err)
+	}
+
+	if profiles, err := LoadProfiles(filepath.Join(t.TempDir(), "missing")); err != nil || profiles != nil {
+		t.Errorf("LoadProfiles() on a missing file = %+v, %v, want no profiles", profiles, err)
+	}
+
+	for _, data := range []string{
+		"[yandex]\nurl = https://caldav.yandex.ru\n",
+		"url = https://caldav.yandex.ru\n",
+		"[profile yandex]\nurl\n",
+		"[profile yandex]\npassword = secret\n",
+	} {
+		if _, err := LoadProfiles(writeTestFile(t, "config", data)); err == nil {
+			t.Errorf("LoadProfiles(%q) succeeded", data)
+		}
+	}
+}
+
+func TestLoadNetrc(t *testing.T) {
+	const netrc = `machine caldav.yandex.ru
+	login alice
+	password netrc-secret
+machine dav.example.org login bob account home password hunter2
+default login anonymous password guest
+macdef init
+	machine ignored.example.org login mallory
+`
+	filename := writeTestFile(t, "netrc", netrc)
+
+	for _, tc := range []struct {
+		host string
+		want *Credentials
+	}{
+		{"caldav.yandex.ru", &Credentials{Username: "alice", Password: "netrc-secret"}},
+		{"DAV.example.org", &Credentials{Username: "bob", Password: "hunter2"}},
+		{"other.example.org", &Credentials{Username: "anonymous", Password: "guest"}},
+		{"ignored.example.org", &Credentials{Username: "anonymous", Password: "guest"}},
+	} {
+		creds, err := LoadNetrc(filename, tc.host)
+		if err != nil {
+			t.Errorf("LoadNetrc(%v) = %v", tc.host, err)
+		} else if !reflect.DeepEqual(creds, tc.want) {
+			t.Errorf("LoadNetrc(%v) = %+v, want %+v", tc.host, creds, tc.want)
+		}
+	}
+
+	creds, err := LoadNetrc(writeTestFile(t, "netrc", "machine caldav.yandex.ru login alice\n"), "other.example.org")
+	if err != nil || creds != nil {
+		t.Errorf("LoadNetrc() without matching entry = %+v, %v, want none", creds, err)
+	}
+	if creds, err := LoadNetrc(filepath.Join(t.TempDir(), "missing"), "caldav.yandex.ru"); err != nil || creds != nil {
+		t.Errorf("LoadNetrc() on a missing file = %+v, %v, want none", creds, err)
+	}
+
+	for _, data := range []string{"machine", "machine caldav.yandex.ru login", "login alice"} {
+		if _, err := LoadNetrc(writeTestFile(t, "netrc", data), "caldav.yandex.ru"); err == nil {
+			t.Errorf("LoadNetrc(%q) succeeded", data)
+		}
+	}
+}
+
+func TestFindCredentials(t *testing.T) {
+	configPath := writeTestFile(t, "config", testConfig)
+	netrcPath := writeTestFile(t, "netrc", `machine caldav.yandex.ru login carol password netrc-secret
+machine other.example.org login dave password other-secret
+`)
+
+	for _, tc := range []struct {
+		name      string
+		url       string
+		profile   string
+		env       [3]string // username, password, profile
+		want      *Credentials
+		wantError error
+	}{
+		{
+			name:    "selected profile over environment",
+			url:     "https://other.example.org",
+			profile: "yandex",
+			env:     [3]string{"erin", "env-secret", ""},
+			want:    &Credentials{Username: "alice", Password: "yandex-secret"},
+		},
+		{
+			name: "profile selected from environment",
+			url:  "https://other.example.org",
+			env:  [3]string{"", "", "home"},
+			want: &Credentials{Username: "bob"},
+		},
+		{
+			name: "environment over matching profile",
+			url:  "https://caldav.yandex.ru",
+			env:  [3]string{"erin", "env-secret", ""},
+			want: &Credentials{Username: "erin", Password: "env-secret"},
+		},
+		{
+			name: "incomplete environment is ignored",
+			url:  "https://caldav.yandex.ru",
+			env:  [3]string{"erin", "", ""},
+			want: &Credentials{Username: "alice", Password: "yandex-secret"},
+		},
+		{
+			name: "matching profile over netrc",
+			url:  "https://caldav.yandex.ru/calendars/",
+			want: &Credentials{Username: "alice", Password: "yandex-secret"},
+		},
+		{
+			name: "netrc",
+			url:  "https://other.example.org:8443/dav/",
+			want: &Credentials{Username: "dave", Password: "other-secret"},
+		},
+		{
+			name:      "no match",
+			url:       "https://unknown.example.org",
+			wantError: ErrNoCredentials,
+		},
+		{
+			name:      "unknown profile",
+			url:       "https://caldav.yandex.ru",
+			profile:   "work",
+			wantError: ErrNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvUsername, tc.env[0])
+			t.Setenv(EnvPassword, tc.env[1])
+			t.Setenv(EnvProfile, tc.env[2])
+
+			creds, err := FindCredentials(tc.url, &CredentialOptions{
+				Profile:    tc.profile,
+				ConfigPath: configPath,
+				NetrcPath:  netrcPath,
+			})
+			if tc.wantError != nil {
+				if !errors.Is(err, tc.wantError) {
+					t.Errorf("FindCredentials() = %+v, %v, want %v", creds, err, tc.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindCredentials() = %v", err)
+			}
+			if !reflect.DeepEqual(creds, tc.want) {
+				t.Errorf("FindCredentials() = %+v, want %+v", creds, tc.want)
+			}
+		})
+	}
+}
@@ -3,12 +3,14 @@ package mycal
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	webdav "github.com/trvita/caldav-client-yandex"
@@ -17,6 +19,9 @@ import (
 	"golang.org/x/term"
 )
 
+// ErrNotFound is returned when a calendar or an event doesn't exist.
+var ErrNotFound = errors.New("not found")
+
 func GetCredentials(r io.Reader) (string, string, error) {
 	reader := bufio.NewReader(r)
 	fmt.Print("username: ")
@@ -45,11 +50,48 @@ func GetCredentials(r io.Reader) (string, string, error) {
 	return username, password, nil
 }
 
+// GetPassword reads a password from r. If r is a terminal, the user is
+// prompted on stderr and the password isn't echoed.
+func GetPassword(r io.Reader) (string, error) {
+	if f, ok := r.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(os.Stderr, "password: ")
+		bytePassword, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		return string(bytePassword), nil
+	}
+
+	password, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || password == "") {
+		return "", err
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
 func CreateClient(url string, r io.Reader) (*caldav.Client, string, context.Context, error) {
 	username, password, err := GetCredentials(r)
 	if err != nil {
 		return nil, "", nil, err
 	}
+	return CreateClientWithCredentials(url, username, password)
+}
+
+// CreateClientWithStoredCredentials is like CreateClient, but uses the
+// credentials found by FindCredentials instead of prompting for them.
+func CreateClientWithStoredCredentials(url string, opts *CredentialOptions) (*caldav.Client, string, context.Context, error) {
+	creds, err := FindCredentials(url, opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if creds.Password == "" {
+		return nil, "", nil, fmt.Errorf("no password stored for user %s: %w", creds.Username, ErrNoCredentials)
+	}
+	return CreateClientWithCredentials(url, creds.Username, creds.Password)
+}
+
+func CreateClientWithCredentials(url, username, password string) (*caldav.Client, string, context.Context, error) {
 	httpClient := webdav.HTTPClientWithBasicAuth(&http.Client{}, username, password)
 	client, err := caldav.NewClient(httpClient, url)
 	if err != nil {
@@ -64,46 +106,57 @@ func CreateClient(url string, r io.Reader) (*caldav.Client, string, context.Cont
 	return client, principal, ctx, nil
 }
 
-func ListCalendars(ctx context.Context, client *caldav.Client, homeset string) error {
-	calendars, err := client.FindCalendars(ctx, homeset)
-	if err != nil {
-		return err
-	}
-	for _, calendar := range calendars {
-		fmt.Printf("Calendar: %s\n", calendar.Name)
-	}
-	return nil
+func ListCalendars(ctx context.Context, client *caldav.Client, homeset string) ([]caldav.Calendar, error) {
+	return client.FindCalendars(ctx, homeset)
 }
 
-func CreateCalendar(ctx context.Context, client *caldav.Client, homeset string, calendarName string, summary string, startDateTime time.Time, endDateTime time.Time) error {
-	err := client.Mkdir(ctx, calendarName)
-	if err != nil {
-		return err
-	}
-	// calendar := ical.NewCalendar()
-	// calendar.Props.SetText(ical.PropVersion, "2.0")
-	// calendar.Props.SetText(ical.PropProductID, "-//Yandex LLC//Yandex Calendar//EN")
-	// calendar.Props.SetText(ical.PropCalendarScale, "GREGORIAN")
-
-	// event, err := GetEvent(summary, startDateTime, endDateTime)
-	// if err != nil {
-	// 	return err
-	// }
-
-	// uid, err := event.Props.Text("UID")
-	// if err != nil {
-	// 	return err
-	// }
-
-	// calendar.Children = append(calendar.Children, event.Component)
-	// calendarURL := homeset + calendarName + "/"
-	// fmt.Println(calendarURL)
-	// _, err = client.PutCalendarObject(ctx, calendarURL+uid+".ics", calendar)
-	// if err != nil {
-	// 	return err
-	// }
+// CreateCalendar creates an event calendar named calendarName in the home
+// set, with an optional description and color. The calendar path is derived
+// from its name.
+func CreateCalendar(ctx context.Context, client *caldav.Client, homeset, calendarName, description, color string) error {
+	return client.CreateCalendar(ctx, &caldav.Calendar{
+		Path:                  homeset + calendarSlug(calendarName) + "/",
+		Name:                  calendarName,
+		Description:           description,
+		Color:                 color,
+		SupportedComponentSet: []string{ical.CompEvent},
+	})
+}
 
-	return nil
+// RenameCalendar changes the display name of the calendar at path. The
+// calendar path stays the same.
+func RenameCalendar(ctx context.Context, client *caldav.Client, path, name string) error {
+	_, err := client.UpdateCalendar(ctx, path, &caldav.CalendarUpdate{Name: &name})
+	return err
+}
+
+// SetCalendarColor changes the color of the calendar at path. An empty color
+// removes it.
+func SetCalendarColor(ctx context.Context, client *caldav.Client, path, color string) error {
+	_, err := client.UpdateCalendar(ctx, path, &caldav.CalendarUpdate{Color: &color})
+	return err
+}
+
+// calendarSlug turns a calendar name into a path segment, made of lowercase
+// letters and digits separated by dashes.
+func calendarSlug(name string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if sb.Len() == 0 {
+		return uuid.NewString()
+	}
+	return sb.String()
 }
 
 func FindCalendar(ctx context.Context, client *caldav.Client, homeset string, calendarName string) (caldav.Calendar, error) {
@@ -118,10 +171,52 @@ func FindCalendar(ctx context.Context, client *caldav.Client, homeset string, ca
 			return calendar, nil
 		}
 	}
-	return calendar, fmt.Errorf("calendar with name %s not found", calendarName)
+	return calendar, fmt.Errorf("calendar with name %s %w", calendarName, ErrNotFound)
 }
 
-func ListEvents(ctx context.Context, client *caldav.Client, calendar caldav.Calendar) error {
+// Event describes a calendar event.
+type Event struct {
+	// Path is the path of the calendar object containing the event
+	Path        string
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+
+	// Object is the calendar object containing the event, Event is the
+	// event itself
+	Object *ical.Calendar
+	Event  *ical.Event
+}
+
+func newEvent(path string, cal *ical.Calendar, event *ical.Event) (Event, error) {
+	e := Event{Path: path, Object: cal, Event: event}
+	e.UID, _ = event.Props.Text(ical.PropUID)
+	e.Summary, _ = event.Props.Text(ical.PropSummary)
+	e.Description, _ = event.Props.Text(ical.PropDescription)
+	e.Location, _ = event.Props.Text(ical.PropLocation)
+
+	var err error
+	if e.Start, err = event.DateTimeStart(time.Local); err != nil {
+		return e, fmt.Errorf("event %s: %v", e.UID, err)
+	}
+	if e.End, err = event.DateTimeEnd(time.Local); err != nil {
+		return e, fmt.Errorf("event %s: %v", e.UID, err)
+	}
+	if prop := event.Props.Get(ical.PropDateTimeStart); prop != nil {
+		e.AllDay = prop.ValueType() == ical.ValueDate
+	}
+	return e, nil
+}
+
+// ListEvents returns the events of calendar overlapping the time range
+// between start and end. If start or end is zero, the range is open-ended on
+// that side. The time range is evaluated by the server, which also expands
+// recurring events into their occurrences if the range is bounded.
+func ListEvents(ctx context.Context, client *caldav.Client, calendar caldav.Calendar, start, end time.Time) ([]Event, error) {
 	query := &caldav.CalendarQuery{
 		CompRequest: caldav.CalendarCompRequest{
 			Name:     "VCALENDAR",
@@ -134,29 +229,35 @@ func ListEvents(ctx context.Context, client *caldav.Client, calendar caldav.Cale
 		CompFilter: caldav.CompFilter{
 			Name: "VCALENDAR",
 			Comps: []caldav.CompFilter{{
-				Name: "VEVENT",
+				Name:  "VEVENT",
+				Start: start,
+				End:   end,
 			}},
 		},
 	}
+	if !start.IsZero() && !end.IsZero() {
+		// List each occurrence of recurring events separately
+		query.CompRequest.Expand = &caldav.CalendarExpandRequest{Start: start, End: end}
+	}
 	cal, err := client.QueryCalendar(
 		ctx,
 		calendar.Path,
 		query,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var events []Event
 	for _, calendarObject := range cal {
 		for _, event := range calendarObject.Data.Events() {
-			for _, prop := range event.Props {
-				for _, p := range prop {
-					fmt.Printf("%s: %s\n", p.Name, p.Value)
-				}
+			e, err := newEvent(calendarObject.Path, calendarObject.Data, &event)
+			if err != nil {
+				return nil, err
 			}
-			fmt.Println()
+			events = append(events, e)
 		}
 	}
-	return nil
+	return events, nil
 }
 
 func GetEvent(summary string, startDateTime time.Time, endDateTime time.Time) (*ical.Event, error) {
@@ -173,7 +274,52 @@ func GetEvent(summary string, startDateTime time.Time, endDateTime time.Time) (*
 	return event, nil
 }
 
+// FindEvent returns the calendar object containing the event with the
+// provided UID. The server only returns the objects matching the UID.
+func FindEvent(ctx context.Context, client *caldav.Client, calendar caldav.Calendar, uid string) (caldav.CalendarObject, error) {
+	query := &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{
+			Name:     "VCALENDAR",
+			AllProps: true,
+			AllComps: true,
+		},
+		CompFilter: caldav.CompFilter{
+			Name: "VCALENDAR",
+			Comps: []caldav.CompFilter{{
+				Name: "VEVENT",
+				Props: []caldav.PropFilter{{
+					Name: ical.PropUID,
+					TextMatch: &caldav.TextMatch{
+						Text:      uid,
+						Collation: caldav.CollationOctet,
+					},
+				}},
+			}},
+		},
+	}
+	objects, err := client.QueryCalendar(ctx, calendar.Path, query)
+	if err != nil {
+		return caldav.CalendarObject{}, err
+	}
+	// text-match is a substring match, so UIDs are compared exactly here
+	for _, object := range objects {
+		for _, event := range object.Data.Events() {
+			if eventUID, _ := event.Props.Text(ical.PropUID); eventUID == uid {
+				return object, nil
+			}
+		}
+	}
+	return caldav.CalendarObject{}, fmt.Errorf("event with UID %s %w", uid, ErrNotFound)
+}
+
 func CreateEvent(ctx context.Context, client *caldav.Client, homeset, calendarName string, event *ical.Event) error {
+	return PutEvent(ctx, client, homeset+calendarName+"/", event)
+}
+
+// PutEvent stores event in the calendar at calendarPath, in a new calendar
+// object named after the event UID. A *caldav.ConflictError is returned if
+// the object already exists.
+func PutEvent(ctx context.Context, client *caldav.Client, calendarPath string, event *ical.Event) error {
 	calendar := ical.NewCalendar()
 	calendar.Props.SetText(ical.PropVersion, "2.0")
 	calendar.Props.SetText(ical.PropProductID, "-//trvita//EN")
@@ -184,8 +330,10 @@ func CreateEvent(ctx context.Context, client *caldav.Client, homeset, calendarNa
 	if err != nil {
 		return err
 	}
-	eventURL := homeset + calendarName + "/" + eventUID + ".ics"
-	_, err = client.PutCalendarObject(ctx, eventURL, calendar)
+	eventURL := calendarPath + eventUID + ".ics"
+	_, err = client.PutCalendarObject(ctx, eventURL, calendar, &caldav.PutCalendarObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatchAny,
+	})
 	if err != nil {
 		return err
 	}
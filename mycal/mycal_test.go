@@ -0,0 +1,169 @@
+package mycal
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trvita/caldav-client-yandex/caldav"
+	"github.com/trvita/go-ical"
+)
+
+func newTestClient(t *testing.T) (*caldav.Client, string, context.Context) {
+	t.Helper()
+
+	b := caldav.NewMemoryBackend("/user/", "/user/calendars/")
+	ts := httptest.NewServer(&caldav.Handler{Backend: b})
+	t.Cleanup(ts.Close)
+
+	client, principal, ctx, err := CreateClientWithCredentials(ts.URL, "alice", "secret")
+	if err != nil {
+		t.Fatalf("CreateClientWithCredentials() = %v", err)
+	}
+	homeset, err := client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		t.Fatalf("FindCalendarHomeSet() = %v", err)
+	}
+	return client, homeset, ctx
+}
+
+func TestCalendarSlug(t *testing.T) {
+	for _, tc := range []struct {
+		name, slug string
+	}{
+		{"Work", "work"},
+		{"  Team  Meetings! ", "team-meetings"},
+		{"Рабочий календарь", "рабочий-календарь"},
+		{"2024/Q1", "2024-q1"},
+	} {
+		if got := calendarSlug(tc.name); got != tc.slug {
+			t.Errorf("calendarSlug(%q) = %q, want %q", tc.name, got, tc.slug)
+		}
+	}
+	if got := calendarSlug("!!!"); got == "" || strings.Contains(got, "!") {
+		t.Errorf("calendarSlug(%q) = %q, want a generated name", "!!!", got)
+	}
+}
+
+func TestGetPassword(t *testing.T) {
+	for _, tc := range []struct {
+		input, password string
+	}{
+		{"secret\n", "secret"},
+		{"secret\r\n", "secret"},
+		{"secret", "secret"},
+		{"with spaces \nignored\n", "with spaces "},
+	} {
+		got, err := GetPassword(strings.NewReader(tc.input))
+		if err != nil {
+			t.Errorf("GetPassword(%q) = %v", tc.input, err)
+		} else if got != tc.password {
+			t.Errorf("GetPassword(%q) = %q, want %q", tc.input, got, tc.password)
+		}
+	}
+	if _, err := GetPassword(strings.NewReader("")); err == nil {
+		t.Errorf("GetPassword() on empty input succeeded")
+	}
+}
+
+func TestCalendars(t *testing.T) {
+	client, homeset, ctx := newTestClient(t)
+
+	if err := CreateCalendar(ctx, client, homeset, "Team Meetings", "Weekly syncs", "#FF0000"); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	calendar, err := FindCalendar(ctx, client, homeset, "Team Meetings")
+	if err != nil {
+		t.Fatalf("FindCalendar() = %v", err)
+	}
+	if calendar.Path != homeset+"team-meetings/" || calendar.Description != "Weekly syncs" || calendar.Color != "#FF0000" {
+		t.Errorf("FindCalendar() = %+v", calendar)
+	}
+
+	if err := RenameCalendar(ctx, client, calendar.Path, "Meetings"); err != nil {
+		t.Fatalf("RenameCalendar() = %v", err)
+	}
+	if err := SetCalendarColor(ctx, client, calendar.Path, "#00FF00"); err != nil {
+		t.Fatalf("SetCalendarColor() = %v", err)
+	}
+	if _, err := FindCalendar(ctx, client, homeset, "Team Meetings"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindCalendar() with the old name = %v, want ErrNotFound", err)
+	}
+	calendar, err = FindCalendar(ctx, client, homeset, "Meetings")
+	if err != nil {
+		t.Fatalf("FindCalendar() after rename = %v", err)
+	}
+	if calendar.Path != homeset+"team-meetings/" || calendar.Color != "#00FF00" {
+		t.Errorf("FindCalendar() after update = %+v", calendar)
+	}
+
+	if err := Delete(ctx, client, calendar.Path); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if calendars, err := ListCalendars(ctx, client, homeset); err != nil || len(calendars) != 0 {
+		t.Errorf("ListCalendars() after Delete() = %+v, %v, want no calendars", calendars, err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	client, homeset, ctx := newTestClient(t)
+
+	if err := CreateCalendar(ctx, client, homeset, "Work", "", ""); err != nil {
+		t.Fatalf("CreateCalendar() = %v", err)
+	}
+	calendar, err := FindCalendar(ctx, client, homeset, "Work")
+	if err != nil {
+		t.Fatalf("FindCalendar() = %v", err)
+	}
+
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	var uids []string
+	for i, summary := range []string{"Standup", "Review"} {
+		event, err := GetEvent(summary, start.AddDate(0, 0, i), start.AddDate(0, 0, i).Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetEvent() = %v", err)
+		}
+		if err := PutEvent(ctx, client, calendar.Path, event); err != nil {
+			t.Fatalf("PutEvent() = %v", err)
+		}
+		uid, _ := event.Props.Text(ical.PropUID)
+		uids = append(uids, uid)
+
+		if err := PutEvent(ctx, client, calendar.Path, event); err == nil {
+			t.Errorf("PutEvent() with an existing UID succeeded")
+		}
+	}
+
+	events, err := ListEvents(ctx, client, calendar, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("ListEvents() = %v", err)
+	}
+	if len(events) != 1 || events[0].Summary != "Standup" || events[0].UID != uids[0] || !events[0].Start.Equal(start) {
+		t.Errorf("ListEvents() = %+v, want the standup only", events)
+	}
+	if events, err := ListEvents(ctx, client, calendar, time.Time{}, time.Time{}); err != nil || len(events) != 2 {
+		t.Errorf("ListEvents() without range = %+v, %v, want 2 events", events, err)
+	}
+
+	object, err := FindEvent(ctx, client, calendar, uids[1])
+	if err != nil {
+		t.Fatalf("FindEvent() = %v", err)
+	}
+	if summary, _ := object.Data.Events()[0].Props.Text(ical.PropSummary); summary != "Review" {
+		t.Errorf("FindEvent() summary = %q, want %q", summary, "Review")
+	}
+	// UIDs are matched exactly, not as substrings
+	if _, err := FindEvent(ctx, client, calendar, uids[1][:8]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindEvent() with a UID prefix = %v, want ErrNotFound", err)
+	}
+
+	if err := Delete(ctx, client, object.Path); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if _, err := FindEvent(ctx, client, calendar, uids[1]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindEvent() after Delete() = %v, want ErrNotFound", err)
+	}
+}
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -85,7 +86,11 @@ func StartMenu(url string) {
 			var principal string
 			var ctx context.Context
 			var err error
-			for {
+			client, principal, ctx, err = mycal.CreateClientWithStoredCredentials(url, nil)
+			if err != nil && !errors.Is(err, mycal.ErrNoCredentials) {
+				RedLine(err)
+			}
+			for err != nil {
 				client, principal, ctx, err = mycal.CreateClient(url, os.Stdin)
 				if err == nil {
 					break
@@ -133,9 +138,13 @@ func CalendarMenu(client *caldav.Client, principal string, ctx context.Context)
 		fmt.Scan(&answer)
 		switch answer {
 		case 1:
-			err := mycal.ListCalendars(ctx, client, homeset)
+			calendars, err := mycal.ListCalendars(ctx, client, homeset)
 			if err != nil {
 				RedLine(err)
+				break
+			}
+			for _, calendar := range calendars {
+				fmt.Printf("Calendar: %s\n", calendar.Name)
 			}
 		case 2:
 			calendarName := GetString("Enter calendar name: ")
@@ -147,8 +156,7 @@ func CalendarMenu(client *caldav.Client, principal string, ctx context.Context)
 			EventMenu(ctx, client, homeset, calendar)
 		case 3:
 			calendarName := GetString("Enter new calendar name: ")
-			summary, startDateTime, endDateTime := GetEvent()
-			err := mycal.CreateCalendar(ctx, client, homeset, calendarName, summary, startDateTime, endDateTime)
+			err := mycal.CreateCalendar(ctx, client, homeset, calendarName, "", "")
 			if err != nil {
 				RedLine(err)
 			} else {
@@ -185,9 +193,18 @@ func EventMenu(ctx context.Context, client *caldav.Client, homeset string, calen
 		fmt.Scan(&answer)
 		switch answer {
 		case 1:
-			err := mycal.ListEvents(ctx, client, calendar)
+			events, err := mycal.ListEvents(ctx, client, calendar, time.Time{}, time.Time{})
 			if err != nil {
 				RedLine(err)
+				break
+			}
+			for _, event := range events {
+				for _, prop := range event.Event.Props {
+					for _, p := range prop {
+						fmt.Printf("%s: %s\n", p.Name, p.Value)
+					}
+				}
+				fmt.Println()
 			}
 		case 2:
 			summary, startDateTime, endDateTime := GetEvent()
@@ -4,6 +4,7 @@
 package webdav
 
 import (
+	"encoding/xml"
 	"time"
 
 	"github.com/trvita/caldav-client-yandex/internal"
@@ -19,6 +20,18 @@ type FileInfo struct {
 	ETag     string
 }
 
+// PropStatus is the outcome of a PROPPATCH request for a single property.
+type PropStatus struct {
+	// Name is the XML name of the property.
+	Name xml.Name
+	// Code is the HTTP status code for the property. Since PROPPATCH
+	// requests are atomic, properties which would have been updated if
+	// another one didn't fail have the code 424 Failed Dependency.
+	Code int
+	// Err is nil if the property was updated.
+	Err error
+}
+
 type CopyOptions struct {
 	NoRecursive bool
 	NoOverwrite bool
@@ -33,12 +46,22 @@ type MoveOptions struct {
 // The (optional) value can either be a wildcard or an ETag.
 type ConditionalMatch string
 
+// ConditionalMatchAny matches any existing resource. Used as If-None-Match,
+// it only allows creating a new resource.
+const ConditionalMatchAny ConditionalMatch = "*"
+
+// ConditionalMatchETag returns a ConditionalMatch matching the resource with
+// the provided ETag.
+func ConditionalMatchETag(etag string) ConditionalMatch {
+	return ConditionalMatch(internal.ETag(etag).String())
+}
+
 func (val ConditionalMatch) IsSet() bool {
 	return val != ""
 }
 
 func (val ConditionalMatch) IsWildcard() bool {
-	return val == "*"
+	return val == ConditionalMatchAny
 }
 
 func (val ConditionalMatch) ETag() (string, error) {